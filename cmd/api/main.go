@@ -1,60 +1,238 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/joaogiacometti/goserver/internal/api"
+	"github.com/joaogiacometti/goserver/internal/config"
 	"github.com/joaogiacometti/goserver/internal/database"
-	"github.com/joho/godotenv"
+	"github.com/joaogiacometti/goserver/internal/entitlements"
+	"github.com/joaogiacometti/goserver/internal/health"
+	"github.com/joaogiacometti/goserver/internal/logging"
+	"github.com/joaogiacometti/goserver/internal/metrics"
+	"github.com/joaogiacometti/goserver/internal/oidc"
+	"github.com/joaogiacometti/goserver/internal/stream"
+	"github.com/joaogiacometti/goserver/internal/tracing"
+	"github.com/joaogiacometti/goserver/internal/webhook"
 )
 
 func main() {
-	godotenv.Load()
+	configPath := flag.String("config", "", "path to a TOML or YAML config file; defaults to $CONFIG_FILE")
+	flag.Parse()
 
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		log.Fatal("DB_URL must be set")
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%s", err)
 	}
 
-	platform := os.Getenv("PLATFORM")
-	if platform == "" {
-		log.Fatal("PLATFORM must be set")
+	logger, err := logging.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		log.Fatalf("invalid logging configuration: %s", err)
+	}
+	slog.SetDefault(logger)
+	slog.Info("effective configuration", "config", cfg)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.ServiceName, os.Stdout)
+	if err != nil {
+		log.Fatalf("invalid tracing configuration: %s", err)
 	}
 
-	jwtTokenSecret := os.Getenv("JWT_TOKEN_SECRET")
-	if jwtTokenSecret == "" {
-		log.Fatal("JWT_TOKEN_SECRET must be set")
+	var passkeys *webauthn.WebAuthn
+	if cfg.WebAuthn.RPID != "" {
+		passkeys, err = webauthn.New(&webauthn.Config{
+			RPID:          cfg.WebAuthn.RPID,
+			RPDisplayName: "Chirpy",
+			RPOrigins:     cfg.WebAuthn.RPOrigins,
+		})
+		if err != nil {
+			log.Fatalf("invalid webauthn configuration: %s", err)
+		}
 	}
 
-	polkaKey := os.Getenv("POLKA_KEY")
-	if polkaKey == "" {
-		log.Fatal("POLKA_KEY must be set")
+	oidcProviders := loadOIDCProviders(cfg.OIDC)
+
+	db, err := sql.Open("postgres", cfg.Database.URL)
+	if err != nil {
+		log.Fatalf("cannot connect with database: %s", err)
 	}
+	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.Database.ConnMaxIdleTime)
 
-	db, err := sql.Open("postgres", dbURL)
+	pingCtx, cancelPing := context.WithTimeout(context.Background(), time.Second*10)
+	err = db.PingContext(pingCtx)
+	cancelPing()
 	if err != nil {
 		log.Fatalf("cannot connect with database: %s", err)
-		return
 	}
 
-	dbQueries := database.New(db)
+	dbQueries := database.New(tracing.WrapDB(db))
+
+	shuttingDown := make(chan struct{})
 
 	apiCfg := api.Api{
-		Db:             dbQueries,
-		Platform:       platform,
-		JwtTokenSecret: jwtTokenSecret,
-		PolkaKey:       polkaKey,
+		Db:                 dbQueries,
+		InTx:               api.SQLTransactor(db),
+		Platform:           cfg.Platform,
+		JwtTokenSecret:     cfg.Auth.JWTSecret,
+		AccessTokenTTL:     cfg.Auth.AccessTokenTTL,
+		RefreshTokenTTL:    cfg.Auth.RefreshTokenTTL,
+		PolkaKey:           cfg.Auth.PolkaKey,
+		WebAuthn:           passkeys,
+		OIDCProviders:      oidcProviders,
+		ChirpStream:        stream.NewHub(1000, 64),
+		MessageStream:      stream.NewHub(1000, 64),
+		NotificationStream: stream.NewHub(1000, 64),
+		Entitlements:       entitlements.NewPlanResolver(dbQueries, entitlements.DefaultPlans),
+		Metrics:            metrics.New(db),
+		MetricsToken:       cfg.Server.MetricsToken,
+		WebhookClient:      webhook.NewClient(cfg.Platform == "dev"),
+		MaxBodySize:        cfg.Server.MaxBodyBytes,
+		ReadinessChecks: []health.Check{
+			health.Database(db),
+			health.Migrations(db, api.SchemaVersion),
+		},
+		DisableSignups:          !cfg.Features.Signups,
+		DisableOutgoingWebhooks: !cfg.Features.OutgoingWebhooks,
+		ShuttingDown:            shuttingDown,
 	}
 
-	serverMux := apiCfg.BindRoutes()
+	for _, email := range cfg.Auth.AdminEmails {
+		_, err := dbQueries.SetUserRoleByEmail(context.Background(), database.SetUserRoleByEmailParams{
+			Role:  api.RoleAdmin,
+			Email: email,
+		})
+		if err != nil {
+			log.Fatalf("cannot promote admin %s: %s", email, err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	for _, worker := range []struct {
+		run      func(context.Context, time.Duration)
+		interval time.Duration
+		disabled bool
+	}{
+		{run: apiCfg.PurgeDeletedUsers, interval: time.Hour},
+		{run: apiCfg.ExpireRedSubscriptions, interval: time.Hour},
+		{run: apiCfg.ProcessWebhookInbox, interval: time.Second * 5},
+		{run: apiCfg.DeliverWebhooks, interval: time.Second * 5, disabled: !cfg.Features.OutgoingWebhooks},
+	} {
+		if worker.disabled {
+			continue
+		}
+
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker.run(workerCtx, worker.interval)
+		}()
+	}
 
 	server := &http.Server{
-		Handler: serverMux,
-		Addr:    ":8080",
+		Handler:           apiCfg.BindRoutes(),
+		Addr:              cfg.Server.Addr,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("server listening", "addr", server.Addr, "tls", cfg.Server.TLS())
+		if cfg.Server.TLS() {
+			serverErr <- server.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+			return
+		}
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("cannot start server: %s", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// A second signal from here on kills the process with the default
+	// behaviour instead of waiting for the drain.
+	slog.Info("shutting down", "delay", cfg.Server.ShutdownDelay, "timeout", cfg.Server.ShutdownTimeout)
+	close(shuttingDown)
+	stopWorkers()
+
+	// Readiness now fails. Keep accepting requests for a while so that load
+	// balancers notice before the listener closes.
+	time.Sleep(cfg.Server.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		slog.Error("error draining requests", "error", err)
+		server.Close()
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		slog.Error("background workers did not stop before the shutdown deadline")
+	}
+
+	err = shutdownTracing(shutdownCtx)
+	if err != nil {
+		slog.Error("error flushing traces", "error", err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		slog.Error("error closing database", "error", err)
+	}
+
+	slog.Info("server stopped")
+}
+
+// loadOIDCProviders discovers every configured identity provider.
+func loadOIDCProviders(configs []config.OIDC) map[string]*oidc.Provider {
+	providers := map[string]*oidc.Provider{}
+
+	for _, provider := range configs {
+		p, err := oidc.NewProvider(context.Background(), oidc.ProviderConfig{
+			Name:         provider.Name,
+			IssuerURL:    provider.IssuerURL,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  provider.RedirectURL,
+		})
+		if err != nil {
+			log.Fatalf("cannot configure identity provider %s: %s", provider.Name, err)
+		}
+
+		providers[provider.Name] = p
 	}
 
-	server.ListenAndServe()
+	return providers
 }
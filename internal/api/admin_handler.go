@@ -1,8 +1,19 @@
 package api
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
 )
 
 func (cfg *Api) handleHitsCount(w http.ResponseWriter, r *http.Request) {
@@ -39,3 +50,362 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(http.StatusText((http.StatusOK))))
 }
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+	UserStatusBanned    = "banned"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+type ResponseAdminUser struct {
+	ID              string     `json:"id"`
+	Email           string     `json:"email"`
+	Role            string     `json:"role"`
+	IsChirpyRed     bool       `json:"is_chirpy_red"`
+	RedStatus       string     `json:"red_status"`
+	Status          string     `json:"status"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusExpiresAt *time.Time `json:"status_expires_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type RequestUserStatus struct {
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type ResponseAuditEntry struct {
+	ID        string     `json:"id"`
+	ActorID   *string    `json:"actor_id"`
+	Action    string     `json:"action"`
+	Reason    string     `json:"reason,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func mapUserToAdminResponse(user database.User) ResponseAdminUser {
+	response := ResponseAdminUser{
+		ID:           user.ID.String(),
+		Email:        user.Email,
+		Role:         user.Role,
+		IsChirpyRed:  user.IsChirpyRed,
+		RedStatus:    user.RedStatus,
+		Status:       user.Status,
+		StatusReason: user.StatusReason.String,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+	}
+	if user.StatusExpiresAt.Valid {
+		response.StatusExpiresAt = &user.StatusExpiresAt.Time
+	}
+	return response
+}
+
+// accountRestriction explains why a user may not sign in or refresh tokens,
+// or returns "" when they may. Suspensions lapse on their own once expired.
+func accountRestriction(user database.User) string {
+	switch user.Status {
+	case UserStatusBanned:
+		return "Account is banned"
+	case UserStatusSuspended:
+		if !user.StatusExpiresAt.Valid || user.StatusExpiresAt.Time.After(time.Now()) {
+			return "Account is suspended"
+		}
+	}
+	return ""
+}
+
+func adminIDFromContext(ctx context.Context) uuid.UUID {
+	adminID, _ := ctx.Value(adminIDKey).(uuid.UUID)
+	return adminID
+}
+
+// recordAudit stores who did what to an account. It runs in the same
+// transaction as the action, so an action is never applied without a record.
+func (cfg *Api) recordAudit(ctx context.Context, q database.Querier, userID uuid.UUID, action, reason string, expiresAt sql.NullTime) error {
+	actorID := adminIDFromContext(ctx)
+
+	return q.CreateUserAuditLog(ctx, database.CreateUserAuditLogParams{
+		UserID:    userID,
+		ActorID:   uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil},
+		Action:    action,
+		Reason:    sql.NullString{String: reason, Valid: reason != ""},
+		ExpiresAt: expiresAt,
+	})
+}
+
+// parsePagination reads limit and offset query parameters, falling back to
+// the defaults when they are missing.
+func parsePagination(r *http.Request) (int32, int32, error) {
+	limit := DefaultPageSize
+	offset := 0
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > MaxPageSize {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", MaxPageSize)
+		}
+		limit = parsed
+	}
+
+	if value := r.URL.Query().Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("offset must not be negative")
+		}
+		offset = parsed
+	}
+
+	return int32(limit), int32(offset), nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes a search term match literally inside an ILIKE pattern
+// declared with ESCAPE '\'.
+func escapeLike(term string) string {
+	return likeEscaper.Replace(term)
+}
+
+func (cfg *Api) handleAdminListUsers(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	users, err := cfg.Db.SearchUsers(r.Context(), database.SearchUsersParams{
+		Column1: escapeLike(r.URL.Query().Get("q")),
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error searching users", "error", err)
+		http.Error(w, "Failed to retrieve users", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponseAdminUser{}
+	for _, user := range users {
+		response = append(response, mapUserToAdminResponse(user))
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleAdminSuspendUser(w http.ResponseWriter, r *http.Request) {
+	cfg.setUserStatus(w, r, UserStatusSuspended)
+}
+
+func (cfg *Api) handleAdminBanUser(w http.ResponseWriter, r *http.Request) {
+	cfg.setUserStatus(w, r, UserStatusBanned)
+}
+
+func (cfg *Api) handleAdminReinstateUser(w http.ResponseWriter, r *http.Request) {
+	cfg.setUserStatus(w, r, UserStatusActive)
+}
+
+func (cfg *Api) setUserStatus(w http.ResponseWriter, r *http.Request, status string) {
+	var request RequestUserStatus
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if userID == adminIDFromContext(r.Context()) {
+		http.Error(w, "You cannot change your own status", http.StatusBadRequest)
+		return
+	}
+
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if status != UserStatusActive && request.Reason == "" {
+		http.Error(w, "A reason is required", http.StatusBadRequest)
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if status == UserStatusSuspended && request.ExpiresAt != nil {
+		if request.ExpiresAt.Before(time.Now()) {
+			http.Error(w, "Expiry must be in the future", http.StatusBadRequest)
+			return
+		}
+		expiresAt = sql.NullTime{Time: *request.ExpiresAt, Valid: true}
+	}
+
+	var user database.User
+	err = cfg.InTx(r.Context(), func(q database.Querier) error {
+		user, err = cfg.applyUserStatus(r.Context(), q, userID, status, request.Reason, expiresAt)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error changing user status", "error", err)
+		http.Error(w, "Failed to change user status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(mapUserToAdminResponse(user))
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// applyUserStatus applies a suspension, ban or reinstatement, ends the user's
+// sessions when they lose access, and records the change in the audit log.
+func (cfg *Api) applyUserStatus(ctx context.Context, q database.Querier, userID uuid.UUID, status, reason string, expiresAt sql.NullTime) (database.User, error) {
+	user, err := q.SetUserStatus(ctx, database.SetUserStatusParams{
+		Status:          status,
+		StatusReason:    sql.NullString{String: reason, Valid: reason != ""},
+		StatusExpiresAt: expiresAt,
+		ID:              userID,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	if status != UserStatusActive {
+		err = q.RevokeAllForUser(ctx, user.ID)
+		if err != nil {
+			return database.User{}, err
+		}
+	}
+
+	action := map[string]string{
+		UserStatusSuspended: "suspend",
+		UserStatusBanned:    "ban",
+		UserStatusActive:    "reinstate",
+	}[status]
+	err = cfg.recordAudit(ctx, q, user.ID, action, reason, expiresAt)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return user, nil
+}
+
+func (cfg *Api) handleAdminUserAudit(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := cfg.Db.GetUserAuditLog(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve audit log", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponseAuditEntry{}
+	for _, entry := range entries {
+		item := ResponseAuditEntry{
+			ID:        entry.ID.String(),
+			Action:    entry.Action,
+			Reason:    entry.Reason.String,
+			CreatedAt: entry.CreatedAt,
+		}
+		if entry.ActorID.Valid {
+			actorID := entry.ActorID.UUID.String()
+			item.ActorID = &actorID
+		}
+		if entry.ExpiresAt.Valid {
+			item.ExpiresAt = &entry.ExpiresAt.Time
+		}
+		response = append(response, item)
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleAdminPromoteUser(w http.ResponseWriter, r *http.Request) {
+	cfg.setUserRole(w, r, RoleAdmin)
+}
+
+func (cfg *Api) handleAdminDemoteUser(w http.ResponseWriter, r *http.Request) {
+	cfg.setUserRole(w, r, RoleUser)
+}
+
+func (cfg *Api) setUserRole(w http.ResponseWriter, r *http.Request, role string) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if userID == adminIDFromContext(r.Context()) {
+		http.Error(w, "You cannot change your own role", http.StatusBadRequest)
+		return
+	}
+
+	action := "promote"
+	if role == RoleUser {
+		action = "demote"
+	}
+
+	var user database.User
+	err = cfg.InTx(r.Context(), func(q database.Querier) error {
+		user, err = q.SetUserRole(r.Context(), database.SetUserRoleParams{
+			Role: role,
+			ID:   userID,
+		})
+		if err != nil {
+			return err
+		}
+		return cfg.recordAudit(r.Context(), q, user.ID, action, "", sql.NullTime{})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error changing user role", "error", err)
+		http.Error(w, "Failed to change user role", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(mapUserToAdminResponse(user))
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+// statusDB applies status changes and audit entries, keeping the writes of
+// a transaction only when it succeeds.
+type statusDB struct {
+	database.Querier
+	users  map[uuid.UUID]database.User
+	audits []database.CreateUserAuditLogParams
+
+	setErr   error
+	auditErr error
+}
+
+func (db *statusDB) SetUserStatus(ctx context.Context, arg database.SetUserStatusParams) (database.User, error) {
+	if db.setErr != nil {
+		return database.User{}, db.setErr
+	}
+	user, ok := db.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.Status = arg.Status
+	user.StatusReason = arg.StatusReason
+	user.StatusExpiresAt = arg.StatusExpiresAt
+	db.users[arg.ID] = user
+	return user, nil
+}
+
+func (db *statusDB) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	return nil
+}
+
+func (db *statusDB) CreateUserAuditLog(ctx context.Context, arg database.CreateUserAuditLogParams) error {
+	if db.auditErr != nil {
+		return db.auditErr
+	}
+	db.audits = append(db.audits, arg)
+	return nil
+}
+
+func (db *statusDB) tx(ctx context.Context, fn func(q database.Querier) error) error {
+	users, audits := maps.Clone(db.users), slices.Clone(db.audits)
+	err := fn(db)
+	if err != nil {
+		db.users, db.audits = users, audits
+	}
+	return err
+}
+
+func TestHandleAdminBanUser(t *testing.T) {
+	adminID := uuid.New()
+
+	tests := []struct {
+		name        string
+		unknownUser bool
+		setErr      error
+		auditErr    error
+		wantStatus  int
+		wantBanned  bool
+	}{
+		{name: "Bans and records the admin", wantStatus: http.StatusOK, wantBanned: true},
+		{name: "Unknown user", unknownUser: true, wantStatus: http.StatusNotFound},
+		{name: "Database error", setErr: errors.New("connection refused"), wantStatus: http.StatusInternalServerError},
+		{name: "Audit failure rolls back", auditErr: errors.New("connection refused"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := database.User{ID: uuid.New(), Status: UserStatusActive}
+			db := &statusDB{users: map[uuid.UUID]database.User{user.ID: user}, setErr: tt.setErr, auditErr: tt.auditErr}
+			cfg := &Api{Db: db, InTx: db.tx}
+
+			target := user.ID
+			if tt.unknownUser {
+				target = uuid.New()
+			}
+			req := newRequest(t, http.MethodPost, "/admin/users/"+target.String()+"/ban", `{"reason":"spam"}`, uuid.Nil)
+			req.SetPathValue("userID", target.String())
+			req = req.WithContext(context.WithValue(req.Context(), adminIDKey, adminID))
+
+			rec := httptest.NewRecorder()
+			cfg.handleAdminBanUser(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if banned := db.users[user.ID].Status == UserStatusBanned; banned != tt.wantBanned {
+				t.Errorf("banned = %v, want %v", banned, tt.wantBanned)
+			}
+			if tt.wantBanned != (len(db.audits) == 1) {
+				t.Fatalf("audit entries = %+v", db.audits)
+			}
+			if tt.wantBanned && (db.audits[0].ActorID.UUID != adminID || db.audits[0].Action != "ban") {
+				t.Errorf("audit entry = %+v", db.audits[0])
+			}
+		})
+	}
+}
+
+func TestRestrictedUsersAreRefused(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		expiresAt  time.Time
+		wantStatus int
+	}{
+		{name: "Active", status: UserStatusActive, wantStatus: http.StatusOK},
+		{name: "Suspended", status: UserStatusSuspended, wantStatus: http.StatusForbidden},
+		{name: "Suspended until later", status: UserStatusSuspended, expiresAt: time.Now().Add(time.Hour), wantStatus: http.StatusForbidden},
+		{name: "Suspension over", status: UserStatusSuspended, expiresAt: time.Now().Add(-time.Hour), wantStatus: http.StatusOK},
+		{name: "Banned", status: UserStatusBanned, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newAccountDB(t)
+			db.user.Status = tt.status
+			if !tt.expiresAt.IsZero() {
+				db.user.StatusExpiresAt = sql.NullTime{Time: tt.expiresAt, Valid: true}
+			}
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+			t.Run("Login", func(t *testing.T) {
+				rec := httptest.NewRecorder()
+				cfg.handleLogin(rec, newRequest(t, http.MethodPost, "/api/login", `{"email":"walt@example.com","password":"04234"}`, uuid.Nil))
+
+				if rec.Code != tt.wantStatus {
+					t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+				}
+			})
+
+			t.Run("Refresh", func(t *testing.T) {
+				db.refresh = database.CreateRefreshTokenParams{Token: "refresh-token", UserID: db.user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+
+				req := newRequest(t, http.MethodPost, "/api/refresh", "", uuid.Nil)
+				req.Header.Set("Authorization", "Bearer refresh-token")
+				rec := httptest.NewRecorder()
+				cfg.handleRefreshToken(rec, req)
+
+				if rec.Code != tt.wantStatus {
+					t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+				}
+			})
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		name string
+		term string
+		want string
+	}{
+		{name: "Plain term", term: "walt@example.com", want: "walt@example.com"},
+		{name: "Percent", term: "100%", want: `100\%`},
+		{name: "Underscore", term: "walt_w", want: `walt\_w`},
+		{name: "Backslash", term: `a\b`, want: `a\\b`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeLike(tt.term); got != tt.want {
+				t.Errorf("escapeLike(%q) = %q, want %q", tt.term, got, tt.want)
+			}
+		})
+	}
+}
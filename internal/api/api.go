@@ -1,19 +1,79 @@
 package api
 
 import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
 	"net/http"
 	"sync/atomic"
+	"time"
 
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
 	"github.com/joaogiacometti/goserver/internal/database"
+	"github.com/joaogiacometti/goserver/internal/entitlements"
+	"github.com/joaogiacometti/goserver/internal/health"
+	"github.com/joaogiacometti/goserver/internal/metrics"
+	"github.com/joaogiacometti/goserver/internal/oidc"
+	"github.com/joaogiacometti/goserver/internal/stream"
+	"github.com/joaogiacometti/goserver/internal/tracing"
 	_ "github.com/lib/pq"
 )
 
 type Api struct {
-	FileserverHits atomic.Int32
-	Db             *database.Queries
-	Platform       string
-	JwtTokenSecret string
-	PolkaKey       string
+	FileserverHits     atomic.Int32
+	Db                 database.Querier
+	InTx               Transactor
+	Platform           string
+	JwtTokenSecret     string
+	AccessTokenTTL     time.Duration
+	RefreshTokenTTL    time.Duration
+	PolkaKey           string
+	WebAuthn           *webauthn.WebAuthn
+	OIDCProviders      map[string]*oidc.Provider
+	ChirpStream        *stream.Hub
+	MessageStream      *stream.Hub
+	NotificationStream *stream.Hub
+	Entitlements       entitlements.Resolver
+	Metrics            *metrics.Metrics
+	MetricsToken       string
+	WebhookClient      *http.Client
+	MaxBodySize        int64
+	ReadinessChecks    []health.Check
+
+	rateLimits rateLimiter
+
+	// Feature switches. The zero value leaves every feature on.
+	DisableSignups          bool
+	DisableOutgoingWebhooks bool
+
+	// ShuttingDown is closed when the server starts draining. Event streams
+	// and websockets end when it closes, since http.Server.Shutdown does not
+	// wait for or interrupt them. A nil channel never closes.
+	ShuttingDown <-chan struct{}
+}
+
+// Transactor runs fn inside a database transaction, committing when fn
+// returns nil and rolling back otherwise.
+type Transactor func(ctx context.Context, fn func(q database.Querier) error) error
+
+// SQLTransactor returns a Transactor that runs transactions against db.
+func SQLTransactor(db *sql.DB) Transactor {
+	return func(ctx context.Context, fn func(q database.Querier) error) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		err = fn(database.New(tracing.WrapDB(tx)))
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
 }
 
 func (cfg *Api) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -23,3 +83,104 @@ func (cfg *Api) middlewareMetricsInc(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// middlewareMetricsToken only lets scrapers presenting MetricsToken through.
+// With no token configured the endpoint does not exist.
+func (cfg *Api) middlewareMetricsToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.MetricsToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.MetricsToken)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// middlewareRequestMetrics records request counts, latency and in-flight
+// requests per route pattern.
+func (cfg *Api) middlewareRequestMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		cfg.Metrics.RequestStarted()
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		cfg.Metrics.RequestFinished(r.Method, r.Pattern, status, time.Since(start))
+	})
+}
+
+// middlewareMaxBodySize rejects request bodies larger than MaxBodySize once
+// a handler reads past the limit. Zero leaves bodies unbounded.
+func (cfg *Api) middlewareMaxBodySize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.MaxBodySize > 0 && r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxBodySize)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// viewerID identifies the caller of an endpoint that also serves anonymous
+// requests. It returns uuid.Nil when no token is sent and an error when the
+// token is present but invalid.
+func (cfg *Api) viewerID(r *http.Request) (uuid.UUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.Nil, nil
+	}
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+}
+
+type contextKey string
+
+const adminIDKey contextKey = "adminID"
+
+// middlewareAdmin only lets through requests carrying an access token of an
+// active user with the admin role. The role is read from the database on
+// every request so promotions and demotions take effect immediately.
+func (cfg *Api) middlewareAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accessToken, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := cfg.Db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if user.Role != RoleAdmin || accountRestriction(user) != "" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), adminIDKey, user.ID)))
+	}
+}
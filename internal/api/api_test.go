@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+const testSecret = "test-secret"
+
+// newRequest builds a request carrying an access token for userID, or no
+// token when userID is uuid.Nil.
+func newRequest(t *testing.T, method, target, body string, userID uuid.UUID) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if userID != uuid.Nil {
+		token, err := auth.MakeJWT(userID, testSecret, auth.DefaultDuration)
+		if err != nil {
+			t.Fatalf("MakeJWT() error = %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+// inlineTx runs "transactions" directly against q, for fakes that have
+// nothing to roll back.
+func inlineTx(q database.Querier) Transactor {
+	return func(ctx context.Context, fn func(q database.Querier) error) error {
+		return fn(q)
+	}
+}
+
+func hashPassword(t *testing.T, password string) string {
+	t.Helper()
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	return hash
+}
+
+func TestMiddlewareMaxBodySize(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int64
+		body    string
+		wantErr bool
+	}{
+		{name: "Under limit", limit: 8, body: "short", wantErr: false},
+		{name: "Over limit", limit: 8, body: "far too long", wantErr: true},
+		{name: "Unbounded", limit: 0, body: "far too long", wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var readErr error
+			cfg := &Api{MaxBodySize: tt.limit}
+			handler := cfg.middlewareMaxBodySize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, readErr = io.ReadAll(r.Body)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/chirps", strings.NewReader(tt.body))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(readErr, &maxBytesErr) != tt.wantErr {
+				t.Errorf("read error = %v, wantErr %v", readErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMiddlewareMetricsToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		header     string
+		wantStatus int
+	}{
+		{name: "Valid token", configured: "scrape", header: "Bearer scrape", wantStatus: http.StatusOK},
+		{name: "Wrong token", configured: "scrape", header: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "No token", configured: "scrape", wantStatus: http.StatusUnauthorized},
+		{name: "Not configured", header: "Bearer ", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Api{MetricsToken: tt.configured}
+			handler := cfg.middlewareMetricsToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestMiddlewareAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		role       string
+		status     string
+		expiresAt  time.Time
+		wantStatus int
+	}{
+		{name: "No token", token: "none", wantStatus: http.StatusUnauthorized},
+		{name: "Invalid token", token: "invalid", wantStatus: http.StatusUnauthorized},
+		{name: "Unknown user", token: "unknown", wantStatus: http.StatusUnauthorized},
+		{name: "Regular user", role: RoleUser, status: UserStatusActive, wantStatus: http.StatusForbidden},
+		{name: "Suspended admin", role: RoleAdmin, status: UserStatusSuspended, wantStatus: http.StatusForbidden},
+		{name: "Banned admin", role: RoleAdmin, status: UserStatusBanned, wantStatus: http.StatusForbidden},
+		{
+			name:       "Admin whose suspension is over",
+			role:       RoleAdmin,
+			status:     UserStatusSuspended,
+			expiresAt:  time.Now().Add(-time.Hour),
+			wantStatus: http.StatusOK,
+		},
+		{name: "Active admin", role: RoleAdmin, status: UserStatusActive, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newSocialDB()
+			userID := db.addUser(false)
+			user := db.users[userID]
+			user.Role = tt.role
+			user.Status = tt.status
+			if !tt.expiresAt.IsZero() {
+				user.StatusExpiresAt = sql.NullTime{Time: tt.expiresAt, Valid: true}
+			}
+			db.users[userID] = user
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+			var gotAdminID uuid.UUID
+			handler := cfg.middlewareAdmin(func(w http.ResponseWriter, r *http.Request) {
+				gotAdminID, _ = r.Context().Value(adminIDKey).(uuid.UUID)
+			})
+
+			var req *http.Request
+			switch tt.token {
+			case "none":
+				req = newRequest(t, http.MethodGet, "/admin/users", "", uuid.Nil)
+			case "invalid":
+				req = newRequest(t, http.MethodGet, "/admin/users", "", uuid.Nil)
+				req.Header.Set("Authorization", "Bearer not-a-jwt")
+			case "unknown":
+				req = newRequest(t, http.MethodGet, "/admin/users", "", uuid.New())
+			default:
+				req = newRequest(t, http.MethodGet, "/admin/users", "", userID)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			wantAdminID := uuid.Nil
+			if tt.wantStatus == http.StatusOK {
+				wantAdminID = userID
+			}
+			if gotAdminID != wantAdminID {
+				t.Errorf("admin ID = %v, want %v", gotAdminID, wantAdminID)
+			}
+		})
+	}
+}
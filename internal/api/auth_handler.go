@@ -1,8 +1,9 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
-	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -10,14 +11,39 @@ import (
 	"github.com/joaogiacometti/goserver/internal/database"
 )
 
+// RefreshTokenDuration is how long refresh tokens last unless
+// Api.RefreshTokenTTL says otherwise.
+const RefreshTokenDuration = time.Hour * 24 * 60
+
+func (cfg *Api) accessTokenTTL() time.Duration {
+	if cfg.AccessTokenTTL > 0 {
+		return cfg.AccessTokenTTL
+	}
+	return auth.DefaultDuration
+}
+
+func (cfg *Api) refreshTokenTTL() time.Duration {
+	if cfg.RefreshTokenTTL > 0 {
+		return cfg.RefreshTokenTTL
+	}
+	return RefreshTokenDuration
+}
+
 type ResponseLogin struct {
-	ID           string    `json:"id"`
-	Email        string    `json:"email"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
-	Token        string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
+	ID              string                  `json:"id"`
+	Email           string                  `json:"email"`
+	CreatedAt       time.Time               `json:"created_at"`
+	UpdatedAt       time.Time               `json:"updated_at"`
+	IsChirpyRed     bool                    `json:"is_chirpy_red"`
+	IsPrivate       bool                    `json:"is_private"`
+	Token           string                  `json:"token"`
+	RefreshToken    string                  `json:"refresh_token"`
+	RedSubscription ResponseRedSubscription `json:"red_subscription"`
+}
+
+type ResponseMFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
 }
 
 type ResponseRefreshToken struct {
@@ -26,13 +52,15 @@ type ResponseRefreshToken struct {
 
 func mapUserToResponseLogin(user database.User, token, refreshToken string) ResponseLogin {
 	return ResponseLogin{
-		ID:           user.ID.String(),
-		Email:        user.Email,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		IsChirpyRed:  user.IsChirpyRed,
-		Token:        token,
-		RefreshToken: refreshToken,
+		ID:              user.ID.String(),
+		Email:           user.Email,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		IsChirpyRed:     user.IsChirpyRed,
+		IsPrivate:       user.IsPrivate,
+		Token:           token,
+		RefreshToken:    refreshToken,
+		RedSubscription: mapRedSubscription(user),
 	}
 }
 
@@ -47,19 +75,93 @@ func (cfg *Api) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	user, err := cfg.Db.GetUserByEmail(r.Context(), request.Email)
 	if err != nil {
+		cfg.Metrics.LoginFailed("unknown_user")
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
 
 	err = auth.CheckPasswordHash(request.Password, user.HashedPassword)
 	if err != nil {
+		cfg.Metrics.LoginFailed("invalid_password")
 		http.Error(w, "Invalid password", http.StatusUnauthorized)
 		return
 	}
 
+	if user.TotpEnabled {
+		cfg.respondWithMFAChallenge(w, r, user)
+		return
+	}
+
+	cfg.completeLogin(w, r, user)
+}
+
+// respondWithMFAChallenge hands out a short-lived token that must be exchanged
+// together with a second factor at /api/login/2fa. Each token is backed by a
+// challenge row that counts wrong codes and can only be redeemed once.
+func (cfg *Api) respondWithMFAChallenge(w http.ResponseWriter, r *http.Request, user database.User) {
+	locked, err := cfg.mfaLockedOut(r.Context(), user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error counting MFA failures", "error", err)
+		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		return
+	}
+	if locked {
+		cfg.Metrics.LoginFailed("mfa_locked")
+		http.Error(w, "Too many failed two-factor attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	challenge, err := cfg.Db.CreateMFAChallenge(r.Context(), database.CreateMFAChallengeParams{
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(auth.MFAChallengeDuration),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating MFA challenge", "error", err)
+		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		return
+	}
+
+	mfaToken, err := auth.MakeMFAToken(user.ID, challenge.ID, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		return
+	}
+
+	response := ResponseMFAChallenge{
+		MFARequired: true,
+		MFAToken:    mfaToken,
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// completeLogin issues an access/refresh token pair for a user who has passed
+// every authentication step, restoring the account if deletion was pending.
+func (cfg *Api) completeLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	if restriction := accountRestriction(user); restriction != "" {
+		cfg.Metrics.LoginFailed("restricted")
+		http.Error(w, restriction, http.StatusForbidden)
+		return
+	}
+
+	if user.DeleteAfter.Valid {
+		err := cfg.Db.CancelUserDeletion(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, "Failed to restore user", http.StatusInternalServerError)
+			return
+		}
+		user.DeleteAfter = sql.NullTime{}
+	}
+
 	token, err := auth.MakeJWT(
 		user.ID,
 		cfg.JwtTokenSecret,
+		cfg.accessTokenTTL(),
 	)
 	if err != nil {
 		http.Error(w, "Failed to create token", http.StatusInternalServerError)
@@ -75,9 +177,11 @@ func (cfg *Api) handleLogin(w http.ResponseWriter, r *http.Request) {
 	cfg.Db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
 		Token:     refreshToken,
 		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(time.Hour * 24 * 60),
+		ExpiresAt: time.Now().Add(cfg.refreshTokenTTL()),
 	})
 
+	cfg.Metrics.LoginSucceeded()
+
 	response := mapUserToResponseLogin(user, token, refreshToken)
 	w.Header().Set("content-type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -108,12 +212,28 @@ func (cfg *Api) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if token.ClientID.Valid {
+		http.Error(w, "Refresh token was issued to an OAuth client", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(r.Context(), token.UserID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+
+	if restriction := accountRestriction(user); restriction != "" {
+		http.Error(w, restriction, http.StatusForbidden)
+		return
+	}
+
 	if token.ExpiresAt.Before(time.Now()) {
 		http.Error(w, "Refresh token has expired", http.StatusUnauthorized)
 		return
 	}
 
-	newToken, err := auth.MakeJWT(token.UserID, cfg.JwtTokenSecret)
+	newToken, err := auth.MakeJWT(token.UserID, cfg.JwtTokenSecret, cfg.accessTokenTTL())
 	if err != nil {
 		http.Error(w, "Failed to create new token", http.StatusInternalServerError)
 		return
@@ -140,7 +260,7 @@ func (cfg *Api) handleRevoke(w http.ResponseWriter, r *http.Request) {
 
 	err = cfg.Db.Revoke(r.Context(), refreshToken)
 	if err != nil {
-		fmt.Println("Error revoking refresh token:", err)
+		slog.ErrorContext(r.Context(), "error revoking refresh token", "error", err)
 		http.Error(w, "Failed to revoke refresh token", http.StatusInternalServerError)
 		return
 	}
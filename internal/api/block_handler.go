@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+type ResponseRelation struct {
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// relationTarget authenticates the caller and resolves the {userID} path
+// value of a block or mute endpoint. It writes the error response itself and
+// reports whether the handler should continue.
+func (cfg *Api) relationTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if targetID == userID {
+		http.Error(w, "You cannot do this to yourself", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, targetID, true
+}
+
+func (cfg *Api) handleBlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.Db.GetUserByID(r.Context(), targetID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	err = cfg.Db.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to block user", http.StatusInternalServerError)
+		return
+	}
+
+	// A block ends any follow relationship in either direction.
+	err = cfg.Db.DeleteFollowsBetween(r.Context(), database.DeleteFollowsBetweenParams{
+		FollowerID: userID,
+		FolloweeID: targetID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error removing follows after block", "error", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *Api) handleUnblockUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.Db.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to unblock user", http.StatusInternalServerError)
+		return
+	}
+	if rows == 0 {
+		http.Error(w, "User is not blocked", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Muting is silent: nothing is recorded or sent that the muted user can see.
+func (cfg *Api) handleMuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.Db.GetUserByID(r.Context(), targetID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	err = cfg.Db.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to mute user", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *Api) handleUnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.Db.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to unmute user", http.StatusInternalServerError)
+		return
+	}
+	if rows == 0 {
+		http.Error(w, "User is not muted", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *Api) handleGetBlocks(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	blocks, err := cfg.Db.GetBlockedUsers(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve blocked users", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponseRelation{}
+	for _, block := range blocks {
+		response = append(response, ResponseRelation{
+			UserID:    block.BlockedID.String(),
+			CreatedAt: block.CreatedAt,
+		})
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleGetMutes(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	mutes, err := cfg.Db.GetMutedUsers(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve muted users", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponseRelation{}
+	for _, mute := range mutes {
+		response = append(response, ResponseRelation{
+			UserID:    mute.MutedID.String(),
+			CreatedAt: mute.CreatedAt,
+		})
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
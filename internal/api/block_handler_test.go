@@ -0,0 +1,293 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+	"github.com/joaogiacometti/goserver/internal/entitlements"
+)
+
+func (db *socialDB) BlockUser(ctx context.Context, arg database.BlockUserParams) error {
+	db.blocks[userPair{arg.BlockerID, arg.BlockedID}] = true
+	return nil
+}
+
+func (db *socialDB) UnblockUser(ctx context.Context, arg database.UnblockUserParams) (int64, error) {
+	pair := userPair{arg.BlockerID, arg.BlockedID}
+	if !db.blocks[pair] {
+		return 0, nil
+	}
+	delete(db.blocks, pair)
+	return 1, nil
+}
+
+func (db *socialDB) MuteUser(ctx context.Context, arg database.MuteUserParams) error {
+	db.mutes[userPair{arg.MuterID, arg.MutedID}] = true
+	return nil
+}
+
+func (db *socialDB) UnmuteUser(ctx context.Context, arg database.UnmuteUserParams) (int64, error) {
+	pair := userPair{arg.MuterID, arg.MutedID}
+	if !db.mutes[pair] {
+		return 0, nil
+	}
+	delete(db.mutes, pair)
+	return 1, nil
+}
+
+func (db *socialDB) DeleteFollowsBetween(ctx context.Context, arg database.DeleteFollowsBetweenParams) error {
+	delete(db.follows, userPair{arg.FollowerID, arg.FolloweeID})
+	delete(db.follows, userPair{arg.FolloweeID, arg.FollowerID})
+	return nil
+}
+
+func (db *socialDB) CreateFollow(ctx context.Context, arg database.CreateFollowParams) (database.Follow, error) {
+	pair := userPair{arg.FollowerID, arg.FolloweeID}
+	if _, ok := db.follows[pair]; ok {
+		return database.Follow{}, sql.ErrNoRows
+	}
+	db.follows[pair] = arg.Status
+	return database.Follow{FollowerID: arg.FollowerID, FolloweeID: arg.FolloweeID, Status: arg.Status}, nil
+}
+
+// relationRequest builds a request for a /api/users/{userID}/... endpoint.
+func relationRequest(t *testing.T, method, action string, userID, targetID uuid.UUID) *http.Request {
+	t.Helper()
+
+	req := newRequest(t, method, "/api/users/"+targetID.String()+"/"+action, "", userID)
+	req.SetPathValue("userID", targetID.String())
+	return req
+}
+
+func TestHandleBlockUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+	}{
+		{name: "Block", target: "bob", wantStatus: http.StatusNoContent},
+		{name: "Themselves", target: "alice", wantStatus: http.StatusBadRequest},
+		{name: "Unknown user", target: "nobody", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newSocialDB()
+			ids := map[string]uuid.UUID{
+				"alice":  db.addUser(false),
+				"bob":    db.addUser(false),
+				"nobody": uuid.New(),
+			}
+			db.follows[userPair{ids["alice"], ids["bob"]}] = FollowStatusAccepted
+			db.follows[userPair{ids["bob"], ids["alice"]}] = FollowStatusAccepted
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+			rec := httptest.NewRecorder()
+			cfg.handleBlockUser(rec, relationRequest(t, http.MethodPost, "block", ids["alice"], ids[tt.target]))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusNoContent {
+				if len(db.blocks) != 0 {
+					t.Errorf("blocks = %v, want none", db.blocks)
+				}
+				return
+			}
+			if !db.blocks[userPair{ids["alice"], ids["bob"]}] {
+				t.Error("block was not recorded")
+			}
+			if len(db.follows) != 0 {
+				t.Errorf("follows = %v, want both directions removed", db.follows)
+			}
+		})
+	}
+}
+
+func TestHandleUnblockUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		blocked    bool
+		wantStatus int
+	}{
+		{name: "Blocked", blocked: true, wantStatus: http.StatusNoContent},
+		{name: "Not blocked", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newSocialDB()
+			alice := db.addUser(false)
+			bob := db.addUser(false)
+			if tt.blocked {
+				db.blocks[userPair{alice, bob}] = true
+			}
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+			rec := httptest.NewRecorder()
+			cfg.handleUnblockUser(rec, relationRequest(t, http.MethodDelete, "block", alice, bob))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if len(db.blocks) != 0 {
+				t.Errorf("blocks = %v, want none", db.blocks)
+			}
+		})
+	}
+}
+
+func TestHandleMuteUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+	}{
+		{name: "Mute", target: "bob", wantStatus: http.StatusNoContent},
+		{name: "Themselves", target: "alice", wantStatus: http.StatusBadRequest},
+		{name: "Unknown user", target: "nobody", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newNotificationDB()
+			ids := map[string]uuid.UUID{
+				"alice":  db.addUser(false),
+				"bob":    db.addUser(false),
+				"nobody": uuid.New(),
+			}
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+			rec := httptest.NewRecorder()
+			cfg.handleMuteUser(rec, relationRequest(t, http.MethodPost, "mute", ids["alice"], ids[tt.target]))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := db.mutes[userPair{ids["alice"], ids["bob"]}]; got != (tt.wantStatus == http.StatusNoContent) {
+				t.Errorf("muted = %v", got)
+			}
+			if len(db.notifications) != 0 {
+				t.Errorf("notifications = %d, muting must be silent", len(db.notifications))
+			}
+		})
+	}
+}
+
+func TestHandleUnmuteUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		muted      bool
+		wantStatus int
+	}{
+		{name: "Muted", muted: true, wantStatus: http.StatusNoContent},
+		{name: "Not muted", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newSocialDB()
+			alice := db.addUser(false)
+			bob := db.addUser(false)
+			if tt.muted {
+				db.mutes[userPair{alice, bob}] = true
+			}
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+			rec := httptest.NewRecorder()
+			cfg.handleUnmuteUser(rec, relationRequest(t, http.MethodDelete, "mute", alice, bob))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if len(db.mutes) != 0 {
+				t.Errorf("mutes = %v, want none", db.mutes)
+			}
+		})
+	}
+}
+
+func TestBlockedUsersAreRefused(t *testing.T) {
+	tests := []struct {
+		name    string
+		blocker string
+	}{
+		{name: "Target blocked the caller", blocker: "bob"},
+		{name: "Caller blocked the target", blocker: "alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/Follow", func(t *testing.T) {
+			db := newNotificationDB()
+			ids := map[string]uuid.UUID{"alice": db.addUser(false), "bob": db.addUser(false)}
+			db.blocks[userPair{ids[tt.blocker], ids[otherUser(tt.blocker)]}] = true
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+			rec := httptest.NewRecorder()
+			cfg.handleFollowUser(rec, relationRequest(t, http.MethodPost, "follow", ids["alice"], ids["bob"]))
+
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+			}
+			if len(db.follows) != 0 || len(db.notifications) != 0 {
+				t.Errorf("follows = %v, notifications = %d, want none", db.follows, len(db.notifications))
+			}
+		})
+
+		t.Run(tt.name+"/Chirp", func(t *testing.T) {
+			db := newSocialDB()
+			ids := map[string]uuid.UUID{"alice": db.addUser(false), "bob": db.addUser(false)}
+			db.blocks[userPair{ids[tt.blocker], ids[otherUser(tt.blocker)]}] = true
+			chirp := database.Chirp{ID: uuid.New(), Body: "hello", UserID: ids["bob"]}
+			db.chirps[chirp.ID] = chirp
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+			req := newRequest(t, http.MethodGet, "/api/chirps/"+chirp.ID.String(), "", ids["alice"])
+			req.SetPathValue("chirpID", chirp.ID.String())
+			rec := httptest.NewRecorder()
+			cfg.handleGetChirp(rec, req)
+
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+			}
+		})
+
+		t.Run(tt.name+"/Message", func(t *testing.T) {
+			social := newSocialDB()
+			ids := map[string]uuid.UUID{"alice": social.addUser(false), "bob": social.addUser(false)}
+			db := newConversationDB(social)
+			conversationID := uuid.New()
+			db.members[conversationID] = []uuid.UUID{ids["alice"], ids["bob"]}
+			db.blocks[userPair{ids[tt.blocker], ids[otherUser(tt.blocker)]}] = true
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret, Entitlements: freePlan}
+
+			req := newRequest(t, http.MethodPost, "/api/conversations/"+conversationID.String()+"/messages", `{"body":"hi"}`, ids["alice"])
+			req.SetPathValue("conversationID", conversationID.String())
+			rec := httptest.NewRecorder()
+			cfg.handleSendMessage(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+			}
+			if len(db.messages) != 0 {
+				t.Errorf("messages = %d, want none", len(db.messages))
+			}
+		})
+	}
+}
+
+var freePlan = resolverFunc(func(ctx context.Context, userID uuid.UUID) (entitlements.Capabilities, error) {
+	return entitlements.DefaultPlans[entitlements.PlanFree], nil
+})
+
+func otherUser(name string) string {
+	if name == "alice" {
+		return "bob"
+	}
+	return "alice"
+}
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
@@ -34,6 +38,68 @@ func MapChirpToResponse(chirp database.Chirp) ResponseChrip {
 	}
 }
 
+// mentionedEmails returns the accounts a chirp body mentions. Accounts have
+// no handles, so a mention is "@" followed by an email address, as in
+// "thanks @walt@example.com!".
+func mentionedEmails(body string) []string {
+	emails := []string{}
+	seen := map[string]bool{}
+	for _, word := range strings.Fields(body) {
+		email, ok := strings.CutPrefix(word, "@")
+		email = strings.TrimRight(email, ".,;:!?)")
+		if !ok || !strings.Contains(email, "@") || seen[strings.ToLower(email)] {
+			continue
+		}
+		seen[strings.ToLower(email)] = true
+		emails = append(emails, email)
+	}
+	return emails
+}
+
+// notifyMentions notifies every account a new chirp mentions, skipping the
+// author and anyone who is blocked or could not see the chirp.
+func (cfg *Api) notifyMentions(ctx context.Context, chirp database.Chirp) {
+	for _, email := range mentionedEmails(chirp.Body) {
+		user, err := cfg.Db.GetUserByEmail(ctx, email)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "error loading mentioned user", "error", err)
+			continue
+		}
+		if user.ID == chirp.UserID {
+			continue
+		}
+
+		blocked, err := cfg.Db.IsBlockedBetween(ctx, database.IsBlockedBetweenParams{
+			BlockerID: user.ID,
+			BlockedID: chirp.UserID,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "error checking blocks for mention", "error", err)
+			continue
+		}
+		if blocked {
+			continue
+		}
+
+		canView, err := cfg.Db.CanViewUserChirps(ctx, database.CanViewUserChirpsParams{
+			AuthorID: chirp.UserID,
+			ViewerID: user.ID,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "error checking chirp visibility for mention", "error", err)
+			continue
+		}
+		if !canView {
+			continue
+		}
+
+		cfg.notify(ctx, user.ID, chirp.UserID, NotificationMention, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	}
+}
+
 func (cfg *Api) handleCreateChirp(w http.ResponseWriter, r *http.Request) {
 	var request RequestChirp
 
@@ -43,7 +109,7 @@ func (cfg *Api) handleCreateChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.JwtTokenSecret)
+	userID, err := auth.ValidateJWTWithScope(token, cfg.JwtTokenSecret, auth.ScopeChirpsWrite)
 	if err != nil {
 		http.Error(w, "Invalid token", http.StatusUnauthorized)
 		return
@@ -55,8 +121,15 @@ func (cfg *Api) handleCreateChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(request.Body) > 140 {
-		http.Error(w, "Chirp body exceeds 140 characters", http.StatusBadRequest)
+	capabilities, err := cfg.Entitlements.Resolve(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error resolving entitlements", "error", err)
+		http.Error(w, "Failed to create chirp", http.StatusInternalServerError)
+		return
+	}
+
+	if len(request.Body) > capabilities.MaxChirpLength {
+		http.Error(w, fmt.Sprintf("Chirp body exceeds %d characters", capabilities.MaxChirpLength), http.StatusBadRequest)
 		w.WriteHeader(400)
 		return
 	}
@@ -85,6 +158,10 @@ func (cfg *Api) handleCreateChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cfg.Metrics.ChirpCreated()
+	cfg.publishChirpEvent(r.Context(), ChirpEventCreated, chirp)
+	cfg.notifyMentions(r.Context(), chirp)
+
 	response := MapChirpToResponse(chirp)
 
 	w.WriteHeader(http.StatusCreated)
@@ -123,20 +200,31 @@ func (cfg *Api) handleGetChirps(w http.ResponseWriter, r *http.Request) {
 		authorID = *authorIDptr
 	}
 
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	var chirps []database.Chirp
-	var err error
 
 	if order == "desc" {
-		chirps, err = cfg.Db.GetAllDesc(r.Context(), authorID)
+		chirps, err = cfg.Db.GetAllDesc(r.Context(), database.GetAllDescParams{
+			Column1: authorID,
+			Column2: viewerID,
+		})
 		if err != nil {
-			fmt.Println("Error retrieving chirps:", err)
+			slog.ErrorContext(r.Context(), "error retrieving chirps", "error", err)
 			http.Error(w, "Failed to retrieve chirps", http.StatusInternalServerError)
 			return
 		}
 	} else {
-		chirps, err = cfg.Db.GetAllAsc(r.Context(), authorID)
+		chirps, err = cfg.Db.GetAllAsc(r.Context(), database.GetAllAscParams{
+			Column1: authorID,
+			Column2: viewerID,
+		})
 		if err != nil {
-			fmt.Println("Error retrieving chirps:", err)
+			slog.ErrorContext(r.Context(), "error retrieving chirps", "error", err)
 			http.Error(w, "Failed to retrieve chirps", http.StatusInternalServerError)
 			return
 		}
@@ -156,6 +244,28 @@ func (cfg *Api) handleGetChirps(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// canViewChirp applies moderation, blocks and private accounts to a single
+// chirp. Callers answer 404 when it is false so that a chirp the viewer may
+// not see is indistinguishable from one that does not exist.
+func (cfg *Api) canViewChirp(ctx context.Context, chirp database.Chirp, viewerID uuid.UUID) (bool, error) {
+	if chirp.HiddenAt.Valid {
+		return false, nil
+	}
+
+	blocked, err := cfg.Db.IsBlockedBetween(ctx, database.IsBlockedBetweenParams{
+		BlockerID: chirp.UserID,
+		BlockedID: viewerID,
+	})
+	if err != nil || blocked {
+		return false, err
+	}
+
+	return cfg.Db.CanViewUserChirps(ctx, database.CanViewUserChirpsParams{
+		AuthorID: chirp.UserID,
+		ViewerID: viewerID,
+	})
+}
+
 func (cfg *Api) handleGetChirp(w http.ResponseWriter, r *http.Request) {
 	chirpIDString := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(chirpIDString)
@@ -164,12 +274,24 @@ func (cfg *Api) handleGetChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	chirp, err := cfg.Db.GetChirpByID(r.Context(), chirpID)
 	if err != nil {
 		http.Error(w, "Failed to retrieve chirp", http.StatusNotFound)
 		return
 	}
 
+	visible, err := cfg.canViewChirp(r.Context(), chirp, viewerID)
+	if err != nil || !visible {
+		http.Error(w, "Failed to retrieve chirp", http.StatusNotFound)
+		return
+	}
+
 	response := MapChirpToResponse(chirp)
 	w.Header().Set("content-type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -194,7 +316,7 @@ func (cfg *Api) handleDeleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	userID, err := auth.ValidateJWTWithScope(accessToken, cfg.JwtTokenSecret, auth.ScopeChirpsWrite)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
@@ -217,5 +339,7 @@ func (cfg *Api) handleDeleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cfg.publishChirpEvent(r.Context(), ChirpEventDeleted, chirp)
+
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+type userPair struct {
+	from uuid.UUID
+	to   uuid.UUID
+}
+
+// socialDB models users, chirps, follows and blocks the way the visibility
+// queries read them.
+type socialDB struct {
+	database.Querier
+	users   map[uuid.UUID]database.User
+	chirps  map[uuid.UUID]database.Chirp
+	follows map[userPair]string
+	blocks  map[userPair]bool
+	mutes   map[userPair]bool
+}
+
+func newSocialDB() *socialDB {
+	return &socialDB{
+		users:   map[uuid.UUID]database.User{},
+		chirps:  map[uuid.UUID]database.Chirp{},
+		follows: map[userPair]string{},
+		blocks:  map[userPair]bool{},
+		mutes:   map[userPair]bool{},
+	}
+}
+
+func (db *socialDB) addUser(private bool) uuid.UUID {
+	user := database.User{ID: uuid.New(), Status: UserStatusActive, IsPrivate: private}
+	db.users[user.ID] = user
+	return user.ID
+}
+
+func (db *socialDB) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, ok := db.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (db *socialDB) GetMutedUsers(ctx context.Context, muterID uuid.UUID) ([]database.UserMute, error) {
+	mutes := []database.UserMute{}
+	for pair := range db.mutes {
+		if pair.from == muterID {
+			mutes = append(mutes, database.UserMute{MuterID: pair.from, MutedID: pair.to})
+		}
+	}
+	return mutes, nil
+}
+
+func (db *socialDB) GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, ok := db.chirps[id]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (db *socialDB) IsBlockedBetween(ctx context.Context, arg database.IsBlockedBetweenParams) (bool, error) {
+	return db.blocks[userPair{arg.BlockerID, arg.BlockedID}] || db.blocks[userPair{arg.BlockedID, arg.BlockerID}], nil
+}
+
+func (db *socialDB) CanViewUserChirps(ctx context.Context, arg database.CanViewUserChirpsParams) (bool, error) {
+	author, ok := db.users[arg.AuthorID]
+	if !ok {
+		return false, nil
+	}
+	if author.Status == UserStatusBanned || author.DeleteAfter.Valid {
+		return false, nil
+	}
+	return !author.IsPrivate || author.ID == arg.ViewerID || db.follows[userPair{arg.ViewerID, arg.AuthorID}] == FollowStatusAccepted, nil
+}
+
+func TestHandleGetChirpVisibility(t *testing.T) {
+	tests := []struct {
+		name       string
+		private    bool
+		viewer     string
+		follow     string
+		blocked    bool
+		hidden     bool
+		banned     bool
+		deleting   bool
+		wantStatus int
+	}{
+		{name: "Public author, anonymous viewer", viewer: "anonymous", wantStatus: http.StatusOK},
+		{name: "Private author, anonymous viewer", private: true, viewer: "anonymous", wantStatus: http.StatusNotFound},
+		{name: "Private author, stranger", private: true, viewer: "stranger", wantStatus: http.StatusNotFound},
+		{name: "Private author, pending follower", private: true, viewer: "stranger", follow: FollowStatusPending, wantStatus: http.StatusNotFound},
+		{name: "Private author, accepted follower", private: true, viewer: "stranger", follow: FollowStatusAccepted, wantStatus: http.StatusOK},
+		{name: "Private author, themselves", private: true, viewer: "author", wantStatus: http.StatusOK},
+		{name: "Public author who blocked the viewer", viewer: "stranger", blocked: true, wantStatus: http.StatusNotFound},
+		{name: "Hidden chirp", viewer: "author", hidden: true, wantStatus: http.StatusNotFound},
+		{name: "Banned author", viewer: "stranger", banned: true, wantStatus: http.StatusNotFound},
+		{name: "Author pending deletion", viewer: "stranger", deleting: true, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newSocialDB()
+			authorID := db.addUser(tt.private)
+			strangerID := db.addUser(false)
+
+			chirp := database.Chirp{ID: uuid.New(), Body: "hello", UserID: authorID}
+			if tt.hidden {
+				chirp.HiddenAt = sql.NullTime{Time: time.Now(), Valid: true}
+			}
+			db.chirps[chirp.ID] = chirp
+			if tt.follow != "" {
+				db.follows[userPair{strangerID, authorID}] = tt.follow
+			}
+			if tt.blocked {
+				db.blocks[userPair{authorID, strangerID}] = true
+			}
+			if tt.banned {
+				author := db.users[authorID]
+				author.Status = UserStatusBanned
+				db.users[authorID] = author
+			}
+			if tt.deleting {
+				author := db.users[authorID]
+				author.DeleteAfter = sql.NullTime{Time: time.Now().Add(AccountDeletionGracePeriod), Valid: true}
+				db.users[authorID] = author
+			}
+
+			viewerID := map[string]uuid.UUID{"anonymous": uuid.Nil, "stranger": strangerID, "author": authorID}[tt.viewer]
+			req := newRequest(t, http.MethodGet, "/api/chirps/"+chirp.ID.String(), "", viewerID)
+			req.SetPathValue("chirpID", chirp.ID.String())
+
+			rec := httptest.NewRecorder()
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+			cfg.handleGetChirp(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
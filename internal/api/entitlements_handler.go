@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/entitlements"
+)
+
+type ResponseEntitlements struct {
+	Plan              string `json:"plan"`
+	MaxChirpLength    int    `json:"max_chirp_length"`
+	RequestsPerMinute int    `json:"requests_per_minute"`
+}
+
+func mapCapabilitiesToResponse(capabilities entitlements.Capabilities) ResponseEntitlements {
+	return ResponseEntitlements{
+		Plan:              string(capabilities.Plan),
+		MaxChirpLength:    capabilities.MaxChirpLength,
+		RequestsPerMinute: capabilities.RequestsPerMinute,
+	}
+}
+
+// handleGetEntitlements tells clients which limits apply to the caller, so
+// they do not have to mirror the plan table themselves.
+func (cfg *Api) handleGetEntitlements(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	capabilities, err := cfg.Entitlements.Resolve(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error resolving entitlements", "error", err)
+		http.Error(w, "Failed to retrieve entitlements", http.StatusInternalServerError)
+		return
+	}
+
+	response := mapCapabilitiesToResponse(capabilities)
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+const (
+	FollowStatusPending  = "pending"
+	FollowStatusAccepted = "accepted"
+)
+
+type RequestPrivacy struct {
+	IsPrivate bool `json:"is_private"`
+}
+
+type ResponseFollow struct {
+	FollowerID string    `json:"follower_id"`
+	FolloweeID string    `json:"followee_id"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func mapFollowToResponse(follow database.Follow) ResponseFollow {
+	return ResponseFollow{
+		FollowerID: follow.FollowerID.String(),
+		FolloweeID: follow.FolloweeID.String(),
+		Status:     follow.Status,
+		CreatedAt:  follow.CreatedAt,
+	}
+}
+
+func (cfg *Api) handleSetPrivacy(w http.ResponseWriter, r *http.Request) {
+	var request RequestPrivacy
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.Db.SetUserPrivacy(r.Context(), database.SetUserPrivacyParams{
+		IsPrivate: request.IsPrivate,
+		ID:        userID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to update privacy", http.StatusInternalServerError)
+		return
+	}
+
+	// Going public lets everyone in, so waiting requests no longer need an answer.
+	if !user.IsPrivate {
+		err = cfg.Db.AcceptPendingFollows(r.Context(), user.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error accepting pending follows", "error", err)
+		}
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(mapUserToResponse(user))
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleFollowUser follows public accounts straight away and files a pending
+// request for private ones. Blocked users are told the account does not exist.
+func (cfg *Api) handleFollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	target, err := cfg.Db.GetUserByID(r.Context(), targetID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	blocked, err := cfg.Db.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+		BlockerID: target.ID,
+		BlockedID: userID,
+	})
+	if err != nil || blocked {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	status := FollowStatusAccepted
+	if target.IsPrivate {
+		status = FollowStatusPending
+	}
+
+	statusCode := http.StatusCreated
+	if status == FollowStatusPending {
+		statusCode = http.StatusAccepted
+	}
+
+	follow, err := cfg.Db.CreateFollow(r.Context(), database.CreateFollowParams{
+		FollowerID: userID,
+		FolloweeID: target.ID,
+		Status:     status,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// Following again is a no-op that reports the existing relationship.
+		statusCode = http.StatusOK
+		follow, err = cfg.Db.GetFollow(r.Context(), database.GetFollowParams{
+			FollowerID: userID,
+			FolloweeID: target.ID,
+		})
+	} else if err == nil {
+		notificationType := NotificationFollow
+		if follow.Status == FollowStatusPending {
+			notificationType = NotificationFollowRequest
+		}
+		cfg.notify(r.Context(), target.ID, userID, notificationType, uuid.NullUUID{})
+	}
+	if err != nil {
+		http.Error(w, "Failed to follow user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(statusCode)
+	err = json.NewEncoder(w).Encode(mapFollowToResponse(follow))
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleUnfollowUser removes a follow or withdraws a pending request.
+func (cfg *Api) handleUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.Db.DeleteFollow(r.Context(), database.DeleteFollowParams{
+		FollowerID: userID,
+		FolloweeID: targetID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to unfollow user", http.StatusInternalServerError)
+		return
+	}
+	if rows == 0 {
+		http.Error(w, "You do not follow this user", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *Api) handleGetFollowRequests(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	follows, err := cfg.Db.GetFollowers(r.Context(), database.GetFollowersParams{
+		FolloweeID: userID,
+		Status:     FollowStatusPending,
+	})
+	if err != nil {
+		http.Error(w, "Failed to retrieve follow requests", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponseFollow{}
+	for _, follow := range follows {
+		response = append(response, mapFollowToResponse(follow))
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleApproveFollowRequest(w http.ResponseWriter, r *http.Request) {
+	userID, followerID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.Db.AcceptFollowRequest(r.Context(), database.AcceptFollowRequestParams{
+		FollowerID: followerID,
+		FolloweeID: userID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to approve follow request", http.StatusInternalServerError)
+		return
+	}
+	if rows == 0 {
+		http.Error(w, "Follow request not found", http.StatusNotFound)
+		return
+	}
+
+	cfg.notify(r.Context(), followerID, userID, NotificationFollowAccepted, uuid.NullUUID{})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDenyFollowRequest drops a pending request. It also removes an
+// accepted follower, which is how private accounts shed existing followers.
+func (cfg *Api) handleDenyFollowRequest(w http.ResponseWriter, r *http.Request) {
+	userID, followerID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.Db.DeleteFollow(r.Context(), database.DeleteFollowParams{
+		FollowerID: followerID,
+		FolloweeID: userID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to deny follow request", http.StatusInternalServerError)
+		return
+	}
+	if rows == 0 {
+		http.Error(w, "Follow request not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/joaogiacometti/goserver/internal/health"
+)
+
+// SchemaVersion is the latest migration in sql/schema. Readiness fails until
+// the database has reached it.
+const SchemaVersion = 24
+
+var ReadinessCheckTimeout = time.Second * 2
+
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+type ResponseHealth struct {
+	Status string                         `json:"status"`
+	Checks map[string]ResponseHealthCheck `json:"checks,omitempty"`
+}
+
+type ResponseHealthCheck struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// handleLivez only reports that the process is serving requests. It does not
+// look at dependencies, so an outage of the database does not get every
+// instance restarted.
+func handleLivez(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, ResponseHealth{Status: HealthStatusOK})
+}
+
+// handleReadyz reports whether this instance should receive traffic. It fails
+// when any dependency check fails and, once shutdown has begun, for the rest
+// of the drain so that load balancers stop routing new requests here.
+func (cfg *Api) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	response := ResponseHealth{
+		Status: HealthStatusOK,
+		Checks: map[string]ResponseHealthCheck{},
+	}
+
+	for _, result := range health.Run(r.Context(), cfg.ReadinessChecks, ReadinessCheckTimeout) {
+		check := ResponseHealthCheck{
+			Status:     HealthStatusOK,
+			DurationMs: result.Duration.Milliseconds(),
+		}
+		if result.Err != nil {
+			slog.WarnContext(r.Context(), "readiness check failed", "check", result.Name, "error", result.Err)
+			check.Status = HealthStatusFail
+			check.Error = healthCheckReason(result.Err)
+			response.Status = HealthStatusFail
+		}
+		response.Checks[result.Name] = check
+	}
+
+	shutdown := ResponseHealthCheck{Status: HealthStatusOK}
+	select {
+	case <-cfg.ShuttingDown:
+		shutdown.Status = HealthStatusFail
+		shutdown.Error = "server is shutting down"
+		response.Status = HealthStatusFail
+	default:
+	}
+	response.Checks["shutdown"] = shutdown
+
+	status := http.StatusOK
+	if response.Status != HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(w, status, response)
+}
+
+// healthCheckReason describes a failed check without the error itself, which
+// can name hosts, users or queries and is only logged.
+func healthCheckReason(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "check timed out"
+	}
+	return "check failed"
+}
+
+func writeHealth(w http.ResponseWriter, status int, response ResponseHealth) {
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("cache-control", "no-store")
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/joaogiacometti/goserver/internal/health"
+)
+
+func TestHandleReadyz(t *testing.T) {
+	passing := health.Check{Name: "database", Run: func(ctx context.Context) error { return nil }}
+	failing := health.Check{Name: "migrations", Run: func(ctx context.Context) error {
+		return errors.New(`pq: password authentication failed for user "chirpy"`)
+	}}
+	closed := make(chan struct{})
+	close(closed)
+
+	tests := []struct {
+		name         string
+		checks       []health.Check
+		shuttingDown chan struct{}
+		wantStatus   int
+		wantFailed   string
+	}{
+		{name: "Ready", checks: []health.Check{passing}, wantStatus: http.StatusOK},
+		{name: "Failing check", checks: []health.Check{passing, failing}, wantStatus: http.StatusServiceUnavailable, wantFailed: "migrations"},
+		{name: "Shutting down", checks: []health.Check{passing}, shuttingDown: closed, wantStatus: http.StatusServiceUnavailable, wantFailed: "shutdown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Api{ReadinessChecks: tt.checks, ShuttingDown: tt.shuttingDown}
+
+			rec := httptest.NewRecorder()
+			cfg.handleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			var response ResponseHealth
+			err := json.NewDecoder(rec.Body).Decode(&response)
+			if err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if len(response.Checks) != len(tt.checks)+1 {
+				t.Errorf("got checks %v", response.Checks)
+			}
+			for name, check := range response.Checks {
+				wantStatus := HealthStatusOK
+				if name == tt.wantFailed {
+					wantStatus = HealthStatusFail
+				}
+				if check.Status != wantStatus {
+					t.Errorf("check %s status = %s, want %s", name, check.Status, wantStatus)
+				}
+				if strings.Contains(check.Error, "chirpy") {
+					t.Errorf("check %s leaks its error: %q", name, check.Error)
+				}
+			}
+		})
+	}
+}
+
+// SchemaVersion has to be bumped with every migration, or readiness would
+// pass against a database that is missing the newest one.
+func TestSchemaVersionMatchesMigrations(t *testing.T) {
+	entries, err := os.ReadDir("../../sql/schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latest := 0
+	for _, entry := range entries {
+		prefix, _, ok := strings.Cut(entry.Name(), "_")
+		if !ok {
+			continue
+		}
+		version, err := strconv.Atoi(prefix)
+		if err == nil && version > latest {
+			latest = version
+		}
+	}
+
+	if latest != SchemaVersion {
+		t.Errorf("SchemaVersion = %d, but the latest migration is %d", SchemaVersion, latest)
+	}
+}
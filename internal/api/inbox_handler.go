@@ -0,0 +1,253 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+const WebhookProviderPolka = "polka"
+
+const (
+	WebhookStatusPending    = "pending"
+	WebhookStatusProcessing = "processing"
+	WebhookStatusDone       = "done"
+	WebhookStatusFailed     = "failed"
+)
+
+// MaxWebhookAttempts is how many times an inbox event is tried before it is
+// dead-lettered as failed and left for an admin to replay.
+const MaxWebhookAttempts = 8
+
+const WebhookBatchSize = 20
+
+// WebhookLease is how long a claimed event stays invisible to other workers.
+// An event still processing after the lease, e.g. because its worker died, is
+// claimed again. Retries back off from WebhookRetryBaseDelay, doubling up to
+// WebhookRetryMaxDelay.
+var (
+	WebhookLease          = time.Minute * 5
+	WebhookRetryBaseDelay = time.Second * 30
+	WebhookRetryMaxDelay  = time.Hour * 6
+)
+
+var errWebhookPayload = errors.New("invalid webhook payload")
+
+type ResponseWebhookEvent struct {
+	ID            string          `json:"id"`
+	Provider      string          `json:"provider"`
+	EventID       string          `json:"event_id"`
+	Event         string          `json:"event"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	ReceivedAt    time.Time       `json:"received_at"`
+	ProcessedAt   *time.Time      `json:"processed_at"`
+}
+
+func mapWebhookEventToResponse(event database.WebhookInbox) ResponseWebhookEvent {
+	response := ResponseWebhookEvent{
+		ID:            event.ID.String(),
+		Provider:      event.Provider,
+		EventID:       event.EventID,
+		Event:         event.Event,
+		Status:        event.Status,
+		Attempts:      event.Attempts,
+		LastError:     event.LastError.String,
+		Payload:       event.Payload,
+		NextAttemptAt: event.NextAttemptAt,
+		ReceivedAt:    event.ReceivedAt,
+	}
+	if event.ProcessedAt.Valid {
+		response.ProcessedAt = &event.ProcessedAt.Time
+	}
+	return response
+}
+
+// webhookRetryDelay doubles the wait after every failed attempt, up to
+// WebhookRetryMaxDelay.
+func webhookRetryDelay(attempts int32) time.Duration {
+	delay := WebhookRetryBaseDelay
+	for i := int32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= WebhookRetryMaxDelay {
+			return WebhookRetryMaxDelay
+		}
+	}
+	return delay
+}
+
+// ProcessWebhookInbox applies stored webhook events. Events are claimed in
+// batches so that several instances can share the inbox, but only the oldest
+// unfinished event of each user, so a user's events apply in the order they
+// arrived. Failures are retried with backoff until MaxWebhookAttempts, or
+// dead-lettered right away when retrying cannot help.
+func (cfg *Api) ProcessWebhookInbox(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			events, err := cfg.Db.ClaimWebhookEvents(ctx, database.ClaimWebhookEventsParams{
+				NextAttemptAt: time.Now().Add(WebhookLease),
+				Limit:         WebhookBatchSize,
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "error claiming webhook events", "error", err)
+				break
+			}
+
+			for _, event := range events {
+				cfg.processWebhookEvent(ctx, event)
+			}
+
+			if len(events) < WebhookBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *Api) processWebhookEvent(ctx context.Context, event database.WebhookInbox) {
+	err := cfg.applyWebhookEvent(ctx, event)
+	if err == nil {
+		cfg.Metrics.WebhookProcessed(event.Provider, WebhookStatusDone)
+		err = cfg.Db.CompleteWebhookEvent(ctx, event.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "error completing webhook event", "error", err)
+		}
+		return
+	}
+
+	lastError := sql.NullString{String: err.Error(), Valid: true}
+
+	permanent := errors.Is(err, errWebhookPayload) || errors.Is(err, errWebhookUserNotFound)
+	if permanent || event.Attempts >= MaxWebhookAttempts {
+		slog.WarnContext(ctx, "webhook event failed", "event_id", event.ID, "attempts", event.Attempts, "error", err)
+		cfg.Metrics.WebhookProcessed(event.Provider, WebhookStatusFailed)
+		err = cfg.Db.FailWebhookEvent(ctx, database.FailWebhookEventParams{
+			LastError: lastError,
+			ID:        event.ID,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "error failing webhook event", "error", err)
+		}
+		return
+	}
+
+	cfg.Metrics.WebhookProcessed(event.Provider, "retry")
+	err = cfg.Db.RetryWebhookEvent(ctx, database.RetryWebhookEventParams{
+		LastError:     lastError,
+		NextAttemptAt: time.Now().Add(webhookRetryDelay(event.Attempts)),
+		ID:            event.ID,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "error rescheduling webhook event", "error", err)
+	}
+}
+
+func (cfg *Api) applyWebhookEvent(ctx context.Context, event database.WebhookInbox) error {
+	if event.Provider != WebhookProviderPolka {
+		return fmt.Errorf("%w: unknown provider %q", errWebhookPayload, event.Provider)
+	}
+
+	var request RequestPolkaWebook
+
+	err := json.Unmarshal(event.Payload, &request)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errWebhookPayload, err)
+	}
+
+	userID, err := uuid.Parse(request.Data.UserId)
+	if err != nil {
+		return fmt.Errorf("%w: invalid user ID", errWebhookPayload)
+	}
+
+	return cfg.applyPolkaEvent(ctx, request, userID, event.ReceivedAt)
+}
+
+func (cfg *Api) handleAdminListWebhooks(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && status != WebhookStatusPending && status != WebhookStatusProcessing &&
+		status != WebhookStatusDone && status != WebhookStatusFailed {
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := cfg.Db.GetWebhookEvents(r.Context(), database.GetWebhookEventsParams{
+		Column1: status,
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error retrieving webhook events", "error", err)
+		http.Error(w, "Failed to retrieve webhook events", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponseWebhookEvent{}
+	for _, event := range events {
+		response = append(response, mapWebhookEventToResponse(event))
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleAdminReplayWebhook puts a dead-lettered event back in the queue with
+// a fresh set of attempts.
+func (cfg *Api) handleAdminReplayWebhook(w http.ResponseWriter, r *http.Request) {
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+
+	event, err := cfg.Db.ReplayWebhookEvent(r.Context(), eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Failed webhook event not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error replaying webhook event", "error", err)
+		http.Error(w, "Failed to replay webhook event", http.StatusInternalServerError)
+		return
+	}
+
+	response := mapWebhookEventToResponse(event)
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
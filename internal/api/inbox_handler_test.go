@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+// inboxDB keeps webhook inbox events next to the polkaDB user they target.
+type inboxDB struct {
+	*polkaDB
+	events map[uuid.UUID]database.WebhookInbox
+}
+
+func (db *inboxDB) CompleteWebhookEvent(ctx context.Context, id uuid.UUID) error {
+	event := db.events[id]
+	event.Status = WebhookStatusDone
+	db.events[id] = event
+	return nil
+}
+
+func (db *inboxDB) RetryWebhookEvent(ctx context.Context, arg database.RetryWebhookEventParams) error {
+	event := db.events[arg.ID]
+	event.Status = WebhookStatusPending
+	event.LastError = arg.LastError
+	event.NextAttemptAt = arg.NextAttemptAt
+	db.events[arg.ID] = event
+	return nil
+}
+
+func (db *inboxDB) FailWebhookEvent(ctx context.Context, arg database.FailWebhookEventParams) error {
+	event := db.events[arg.ID]
+	event.Status = WebhookStatusFailed
+	event.LastError = arg.LastError
+	db.events[arg.ID] = event
+	return nil
+}
+
+func (db *inboxDB) ReplayWebhookEvent(ctx context.Context, id uuid.UUID) (database.WebhookInbox, error) {
+	event, ok := db.events[id]
+	if !ok || event.Status != WebhookStatusFailed {
+		return database.WebhookInbox{}, sql.ErrNoRows
+	}
+	event.Status = WebhookStatusPending
+	event.Attempts = 0
+	event.LastError = sql.NullString{}
+	db.events[id] = event
+	return event, nil
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{attempts: 0, want: WebhookRetryBaseDelay},
+		{attempts: 1, want: WebhookRetryBaseDelay},
+		{attempts: 2, want: WebhookRetryBaseDelay * 2},
+		{attempts: 4, want: WebhookRetryBaseDelay * 8},
+		{attempts: 20, want: WebhookRetryMaxDelay},
+		{attempts: MaxWebhookAttempts * 100, want: WebhookRetryMaxDelay},
+	}
+
+	for _, tt := range tests {
+		if got := webhookRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("webhookRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestProcessWebhookEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    string
+		knownUser  bool
+		userErr    error
+		attempts   int32
+		wantStatus string
+	}{
+		{name: "Applied", knownUser: true, attempts: 1, wantStatus: WebhookStatusDone},
+		{name: "Transient failure is retried", knownUser: true, userErr: errors.New("connection refused"), attempts: 1, wantStatus: WebhookStatusPending},
+		{name: "Dead-lettered after the last attempt", knownUser: true, userErr: errors.New("connection refused"), attempts: MaxWebhookAttempts, wantStatus: WebhookStatusFailed},
+		{name: "Unknown user is dead-lettered", attempts: 1, wantStatus: WebhookStatusFailed},
+		{name: "Invalid payload is dead-lettered", payload: `{"data":{"user_id":"nope"}}`, attempts: 1, wantStatus: WebhookStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := database.User{ID: uuid.New(), RedStatus: RedStatusNone}
+			db := &inboxDB{polkaDB: &polkaDB{user: user, userErr: tt.userErr}, events: map[uuid.UUID]database.WebhookInbox{}}
+			cfg := &Api{Db: db, DisableOutgoingWebhooks: true}
+
+			target := user.ID
+			if !tt.knownUser {
+				target = uuid.New()
+			}
+			payload := tt.payload
+			if payload == "" {
+				payload = `{"id":"evt_1","event":"user.upgraded","data":{"user_id":"` + target.String() + `"}}`
+			}
+
+			event := database.WebhookInbox{
+				ID:         uuid.New(),
+				Provider:   WebhookProviderPolka,
+				Event:      UserUpgraded,
+				Payload:    json.RawMessage(payload),
+				Status:     WebhookStatusProcessing,
+				Attempts:   tt.attempts,
+				ReceivedAt: time.Now(),
+			}
+			db.events[event.ID] = event
+
+			cfg.processWebhookEvent(context.Background(), event)
+
+			got := db.events[event.ID]
+			if got.Status != tt.wantStatus {
+				t.Fatalf("status = %s (%s), want %s", got.Status, got.LastError.String, tt.wantStatus)
+			}
+			if tt.wantStatus == WebhookStatusPending {
+				want := time.Now().Add(webhookRetryDelay(tt.attempts))
+				if got.NextAttemptAt.Before(want.Add(-time.Minute)) || got.NextAttemptAt.After(want) {
+					t.Errorf("next attempt at %v, want about %v", got.NextAttemptAt, want)
+				}
+			}
+			if tt.wantStatus != WebhookStatusDone && !got.LastError.Valid {
+				t.Error("expected the failure to be recorded")
+			}
+		})
+	}
+}
+
+func TestHandleAdminReplayWebhook(t *testing.T) {
+	failed := database.WebhookInbox{ID: uuid.New(), Status: WebhookStatusFailed, Attempts: MaxWebhookAttempts, LastError: sql.NullString{String: "boom", Valid: true}}
+	done := database.WebhookInbox{ID: uuid.New(), Status: WebhookStatusDone, Attempts: 1}
+
+	tests := []struct {
+		name       string
+		eventID    string
+		wantStatus int
+	}{
+		{name: "Failed event", eventID: failed.ID.String(), wantStatus: http.StatusAccepted},
+		{name: "Event that did not fail", eventID: done.ID.String(), wantStatus: http.StatusNotFound},
+		{name: "Unknown event", eventID: uuid.NewString(), wantStatus: http.StatusNotFound},
+		{name: "Invalid ID", eventID: "nope", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &inboxDB{polkaDB: &polkaDB{}, events: map[uuid.UUID]database.WebhookInbox{failed.ID: failed, done.ID: done}}
+			cfg := &Api{Db: db}
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/webhooks/"+tt.eventID+"/replay", nil)
+			req.SetPathValue("eventID", tt.eventID)
+			rec := httptest.NewRecorder()
+			cfg.handleAdminReplayWebhook(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusAccepted {
+				return
+			}
+
+			var response ResponseWebhookEvent
+			err := json.NewDecoder(rec.Body).Decode(&response)
+			if err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if response.Status != WebhookStatusPending || response.Attempts != 0 || response.LastError != "" {
+				t.Errorf("replayed event = %+v", response)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/logging"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// maxLoggedErrorSize caps how much of a 5xx response body, usually the
+// message passed to http.Error, is copied into the access log.
+const maxLoggedErrorSize = 256
+
+// middlewareRequestID keeps the caller's X-Request-ID when it looks sane and
+// otherwise assigns one. The ID is echoed in the response and attached to
+// every log record written with the request context.
+func middlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	return !strings.ContainsFunc(requestID, func(c rune) bool {
+		return c < '!' || c > '~'
+	})
+}
+
+// middlewareAccessLog writes one record per request. Server errors are
+// logged at error level with the start of the response body, so failures
+// that handlers only report to the client still show up in the logs.
+func (cfg *Api) middlewareAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", r.Pattern),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("bytes", recorder.bytes),
+		}
+		if userID, ok := cfg.requestUserID(r); ok {
+			attrs = append(attrs, slog.String("user_id", userID.String()))
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+			attrs = append(attrs, slog.String("error", strings.TrimSpace(string(recorder.errorBody))))
+		}
+
+		slog.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+// requestUserID reads the user from the access token, if any, for logging
+// only; handlers still authenticate the request themselves.
+func (cfg *Api) requestUserID(r *http.Request) (uuid.UUID, bool) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	claims, err := auth.ParseAccessToken(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	userID, err := claims.UserID()
+	return userID, err == nil
+}
+
+// statusRecorder captures what a handler wrote. Unwrap keeps flushing and
+// hijacking available to the stream and WebSocket handlers.
+type statusRecorder struct {
+	http.ResponseWriter
+	status    int
+	bytes     int
+	errorBody []byte
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	if s.status >= http.StatusInternalServerError && len(s.errorBody) < maxLoggedErrorSize {
+		s.errorBody = append(s.errorBody, b[:min(len(b), maxLoggedErrorSize-len(s.errorBody))]...)
+	}
+
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
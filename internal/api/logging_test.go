@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/logging"
+)
+
+func TestMiddlewareRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantSame bool
+	}{
+		{name: "Propagates caller ID", incoming: "req-abc-123", wantSame: true},
+		{name: "Assigns when missing", incoming: "", wantSame: false},
+		{name: "Replaces invalid ID", incoming: "has spaces in it", wantSame: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := middlewareRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = logging.RequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/healthz", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(RequestIDHeader)
+			if got == "" || got != seen {
+				t.Fatalf("response ID %q, context ID %q", got, seen)
+			}
+			if (got == tt.incoming) != tt.wantSame {
+				t.Errorf("request ID = %q, incoming %q", got, tt.incoming)
+			}
+		})
+	}
+}
+
+func TestMiddlewareAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "info", "json")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	cfg := &Api{JwtTokenSecret: "secret"}
+	userID := uuid.New()
+	token, err := auth.MakeJWT(userID, cfg.JwtTokenSecret, auth.DefaultDuration)
+	if err != nil {
+		t.Fatalf("MakeJWT() error = %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/things/{thingID}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Failed to retrieve thing", http.StatusInternalServerError)
+	})
+	handler := middlewareRequestID(cfg.middlewareAccessLog(mux))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/things/42", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]any
+	err = json.Unmarshal(buf.Bytes(), &record)
+	if err != nil {
+		t.Fatalf("invalid JSON log line %q: %v", buf.String(), err)
+	}
+
+	want := map[string]any{
+		"level":      "ERROR",
+		"method":     "GET",
+		"route":      "GET /api/things/{thingID}",
+		"status":     float64(http.StatusInternalServerError),
+		"user_id":    userID.String(),
+		"request_id": "req-1",
+		"error":      "Failed to retrieve thing",
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("%s = %v, want %v", key, record[key], value)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+	"github.com/joaogiacometti/goserver/internal/stream"
+)
+
+// MaxConversationMembers caps group conversations, creator included.
+const MaxConversationMembers = 10
+
+type RequestConversation struct {
+	MemberIDs []string `json:"member_ids"`
+}
+
+type RequestMessage struct {
+	Body string `json:"body"`
+}
+
+type ResponseConversation struct {
+	ID          string     `json:"id"`
+	MemberIDs   []string   `json:"member_ids"`
+	UnreadCount int64      `json:"unread_count"`
+	LastReadAt  *time.Time `json:"last_read_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type ResponseMessage struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	SenderID       string    `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func mapMessageToResponse(message database.Message) ResponseMessage {
+	return ResponseMessage{
+		ID:             message.ID.String(),
+		ConversationID: message.ConversationID.String(),
+		SenderID:       message.SenderID.String(),
+		Body:           message.Body,
+		CreatedAt:      message.CreatedAt,
+	}
+}
+
+func (cfg *Api) publishMessageEvent(message database.Message) {
+	if cfg.MessageStream == nil {
+		return
+	}
+
+	data, err := json.Marshal(mapMessageToResponse(message))
+	if err != nil {
+		slog.Error("error encoding message event", "error", err)
+		return
+	}
+
+	cfg.MessageStream.Publish(stream.Event{
+		Type:   MessageEventCreated,
+		UserID: message.SenderID,
+		Topic:  message.ConversationID,
+		Data:   data,
+	})
+}
+
+func (cfg *Api) mapConversationToResponse(ctx context.Context, conversation database.Conversation) (ResponseConversation, error) {
+	members, err := cfg.Db.GetConversationMembers(ctx, conversation.ID)
+	if err != nil {
+		return ResponseConversation{}, err
+	}
+
+	return mapConversationMembers(conversation.ID, conversation.CreatedAt, conversation.UpdatedAt, members), nil
+}
+
+func mapConversationMembers(id uuid.UUID, createdAt, updatedAt time.Time, members []database.ConversationMember) ResponseConversation {
+	response := ResponseConversation{
+		ID:        id.String(),
+		MemberIDs: []string{},
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+	}
+	for _, member := range members {
+		response.MemberIDs = append(response.MemberIDs, member.UserID.String())
+	}
+	return response
+}
+
+// directConversationKey names the one-to-one conversation between two users
+// the same way whichever of them starts it. conversations.direct_key is
+// unique, so concurrent first messages land in the same conversation.
+func directConversationKey(a, b uuid.UUID) string {
+	if b.String() < a.String() {
+		a, b = b, a
+	}
+	return a.String() + ":" + b.String()
+}
+
+// conversationForMember authenticates the caller and resolves the
+// {conversationID} path value, answering 404 to anyone outside the
+// conversation. It writes the error response itself.
+func (cfg *Api) conversationForMember(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	isMember, err := cfg.Db.IsConversationMember(r.Context(), database.IsConversationMemberParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil || !isMember {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, conversationID, true
+}
+
+// handleCreateConversation starts a conversation with one or more users. A
+// one-to-one conversation is reused if it already exists.
+func (cfg *Api) handleCreateConversation(w http.ResponseWriter, r *http.Request) {
+	var request RequestConversation
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	memberIDs := []uuid.UUID{}
+	seen := map[uuid.UUID]bool{userID: true}
+	for _, raw := range request.MemberIDs {
+		memberID, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "Invalid member ID", http.StatusBadRequest)
+			return
+		}
+		if seen[memberID] {
+			continue
+		}
+		seen[memberID] = true
+		memberIDs = append(memberIDs, memberID)
+	}
+
+	if len(memberIDs) == 0 || len(memberIDs) >= MaxConversationMembers {
+		http.Error(w, fmt.Sprintf("A conversation needs between 1 and %d other members", MaxConversationMembers-1), http.StatusBadRequest)
+		return
+	}
+
+	for _, memberID := range memberIDs {
+		_, err = cfg.Db.GetUserByID(r.Context(), memberID)
+		if err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	// A block in either direction between any two participants keeps them
+	// out of a conversation together, whoever blocked whom and whoever is
+	// starting it.
+	participants := append([]uuid.UUID{userID}, memberIDs...)
+	for i, a := range participants {
+		for _, b := range participants[i+1:] {
+			blocked, err := cfg.Db.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+				BlockerID: a,
+				BlockedID: b,
+			})
+			if err != nil || blocked {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+		}
+	}
+
+	statusCode := http.StatusCreated
+
+	var conversation database.Conversation
+	err = cfg.InTx(r.Context(), func(q database.Querier) error {
+		if len(memberIDs) == 1 {
+			direct, err := q.CreateDirectConversation(r.Context(), sql.NullString{
+				String: directConversationKey(userID, memberIDs[0]),
+				Valid:  true,
+			})
+			if err != nil {
+				return err
+			}
+			conversation = database.Conversation{
+				ID:        direct.ID,
+				CreatedAt: direct.CreatedAt,
+				UpdatedAt: direct.UpdatedAt,
+				DirectKey: direct.DirectKey,
+			}
+			if !direct.Inserted {
+				statusCode = http.StatusOK
+				return nil
+			}
+		} else {
+			var err error
+			conversation, err = q.CreateConversation(r.Context())
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, memberID := range participants {
+			err := q.AddConversationMember(r.Context(), database.AddConversationMemberParams{
+				ConversationID: conversation.ID,
+				UserID:         memberID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating conversation", "error", err)
+		http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
+		return
+	}
+
+	response, err := cfg.mapConversationToResponse(r.Context(), conversation)
+	if err != nil {
+		http.Error(w, "Failed to retrieve conversation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(statusCode)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleGetConversations(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversations, err := cfg.Db.GetConversationsForUser(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error retrieving conversations", "error", err)
+		http.Error(w, "Failed to retrieve conversations", http.StatusInternalServerError)
+		return
+	}
+
+	conversationIDs := []uuid.UUID{}
+	for _, row := range conversations {
+		conversationIDs = append(conversationIDs, row.ID)
+	}
+
+	members := map[uuid.UUID][]database.ConversationMember{}
+	if len(conversationIDs) > 0 {
+		rows, err := cfg.Db.GetMembersOfConversations(r.Context(), conversationIDs)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error retrieving conversation members", "error", err)
+			http.Error(w, "Failed to retrieve conversations", http.StatusInternalServerError)
+			return
+		}
+		for _, member := range rows {
+			members[member.ConversationID] = append(members[member.ConversationID], member)
+		}
+	}
+
+	response := []ResponseConversation{}
+	for _, row := range conversations {
+		item := mapConversationMembers(row.ID, row.CreatedAt, row.UpdatedAt, members[row.ID])
+		item.UnreadCount = row.UnreadCount
+		if row.LastReadAt.Valid {
+			item.LastReadAt = &row.LastReadAt.Time
+		}
+		response = append(response, item)
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleGetMessages(w http.ResponseWriter, r *http.Request) {
+	_, conversationID, ok := cfg.conversationForMember(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	messages, err := cfg.Db.GetMessages(r.Context(), database.GetMessagesParams{
+		ConversationID: conversationID,
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		http.Error(w, "Failed to retrieve messages", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponseMessage{}
+	for _, message := range messages {
+		response = append(response, mapMessageToResponse(message))
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSendMessage posts to a conversation. Once any member has blocked the
+// sender, or the sender has blocked any member, the conversation is read-only
+// for them.
+func (cfg *Api) handleSendMessage(w http.ResponseWriter, r *http.Request) {
+	var request RequestMessage
+
+	userID, conversationID, ok := cfg.conversationForMember(w, r)
+	if !ok {
+		return
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	capabilities, err := cfg.Entitlements.Resolve(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error resolving entitlements", "error", err)
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		return
+	}
+
+	if request.Body == "" || len(request.Body) > capabilities.MaxChirpLength {
+		http.Error(w, fmt.Sprintf("Message body must be between 1 and %d characters", capabilities.MaxChirpLength), http.StatusBadRequest)
+		return
+	}
+
+	blocked, err := cfg.Db.HasBlockInConversation(r.Context(), database.HasBlockInConversationParams{
+		UserID:         userID,
+		ConversationID: conversationID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		http.Error(w, "You cannot message this conversation", http.StatusForbidden)
+		return
+	}
+
+	message, err := cfg.Db.CreateMessage(r.Context(), database.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Body:           request.Body,
+	})
+	if err != nil {
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		return
+	}
+
+	err = cfg.Db.TouchConversation(r.Context(), conversationID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error updating conversation", "error", err)
+	}
+
+	cfg.publishMessageEvent(message)
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(mapMessageToResponse(message))
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleMarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	userID, conversationID, ok := cfg.conversationForMember(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.Db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to mark conversation as read", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,310 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+	"github.com/joaogiacometti/goserver/internal/entitlements"
+)
+
+// conversationDB adds conversations to socialDB and remembers whether they
+// were written inside a transaction.
+type conversationDB struct {
+	*socialDB
+	members     map[uuid.UUID][]uuid.UUID
+	directKeys  map[string]uuid.UUID
+	memberReads int
+	messages    []database.Message
+	inTx        bool
+	outside     int
+}
+
+func (db *conversationDB) tx(ctx context.Context, fn func(q database.Querier) error) error {
+	db.inTx = true
+	defer func() { db.inTx = false }()
+	return fn(db)
+}
+
+func (db *conversationDB) CreateDirectConversation(ctx context.Context, directKey sql.NullString) (database.CreateDirectConversationRow, error) {
+	if !db.inTx {
+		db.outside++
+	}
+	if id, ok := db.directKeys[directKey.String]; ok {
+		return database.CreateDirectConversationRow{ID: id, DirectKey: directKey}, nil
+	}
+	id := uuid.New()
+	db.directKeys[directKey.String] = id
+	db.members[id] = nil
+	return database.CreateDirectConversationRow{ID: id, DirectKey: directKey, Inserted: true}, nil
+}
+
+func (db *conversationDB) CreateConversation(ctx context.Context) (database.Conversation, error) {
+	if !db.inTx {
+		db.outside++
+	}
+	conversation := database.Conversation{ID: uuid.New()}
+	db.members[conversation.ID] = nil
+	return conversation, nil
+}
+
+func (db *conversationDB) AddConversationMember(ctx context.Context, arg database.AddConversationMemberParams) error {
+	if !db.inTx {
+		db.outside++
+	}
+	db.members[arg.ConversationID] = append(db.members[arg.ConversationID], arg.UserID)
+	return nil
+}
+
+func (db *conversationDB) GetConversationMembers(ctx context.Context, conversationID uuid.UUID) ([]database.ConversationMember, error) {
+	members := []database.ConversationMember{}
+	for _, userID := range db.members[conversationID] {
+		members = append(members, database.ConversationMember{ConversationID: conversationID, UserID: userID})
+	}
+	return members, nil
+}
+
+func (db *conversationDB) GetMembersOfConversations(ctx context.Context, conversationIDs []uuid.UUID) ([]database.ConversationMember, error) {
+	db.memberReads++
+	members := []database.ConversationMember{}
+	for _, conversationID := range conversationIDs {
+		for _, userID := range db.members[conversationID] {
+			members = append(members, database.ConversationMember{ConversationID: conversationID, UserID: userID})
+		}
+	}
+	return members, nil
+}
+
+func (db *conversationDB) GetConversationsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetConversationsForUserRow, error) {
+	rows := []database.GetConversationsForUserRow{}
+	for id, members := range db.members {
+		for _, member := range members {
+			if member == userID {
+				rows = append(rows, database.GetConversationsForUserRow{ID: id})
+			}
+		}
+	}
+	return rows, nil
+}
+
+func (db *conversationDB) IsConversationMember(ctx context.Context, arg database.IsConversationMemberParams) (bool, error) {
+	for _, member := range db.members[arg.ConversationID] {
+		if member == arg.UserID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (db *conversationDB) HasBlockInConversation(ctx context.Context, arg database.HasBlockInConversationParams) (bool, error) {
+	for _, member := range db.members[arg.ConversationID] {
+		if db.blocks[userPair{member, arg.UserID}] || db.blocks[userPair{arg.UserID, member}] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (db *conversationDB) CreateMessage(ctx context.Context, arg database.CreateMessageParams) (database.Message, error) {
+	message := database.Message{ID: uuid.New(), ConversationID: arg.ConversationID, SenderID: arg.SenderID, Body: arg.Body}
+	db.messages = append(db.messages, message)
+	return message, nil
+}
+
+func (db *conversationDB) TouchConversation(ctx context.Context, id uuid.UUID) error {
+	return nil
+}
+
+func newConversationDB(social *socialDB) *conversationDB {
+	return &conversationDB{
+		socialDB:   social,
+		members:    map[uuid.UUID][]uuid.UUID{},
+		directKeys: map[string]uuid.UUID{},
+	}
+}
+
+func TestHandleCreateConversation(t *testing.T) {
+	tests := []struct {
+		name        string
+		members     []string
+		blocks      [][2]string
+		existingDM  bool
+		wantStatus  int
+		wantMembers int
+	}{
+		{name: "Direct message", members: []string{"bob"}, wantStatus: http.StatusCreated, wantMembers: 2},
+		{name: "Existing direct message", members: []string{"bob"}, existingDM: true, wantStatus: http.StatusOK, wantMembers: 2},
+		{name: "Group", members: []string{"bob", "carol"}, wantStatus: http.StatusCreated, wantMembers: 3},
+		{name: "Recipient blocked sender", members: []string{"bob"}, blocks: [][2]string{{"bob", "alice"}}, wantStatus: http.StatusNotFound},
+		{name: "Sender blocked recipient", members: []string{"bob"}, blocks: [][2]string{{"alice", "bob"}}, wantStatus: http.StatusNotFound},
+		{name: "Members blocked each other", members: []string{"bob", "carol"}, blocks: [][2]string{{"carol", "bob"}}, wantStatus: http.StatusNotFound},
+		{name: "Unknown user", members: []string{uuid.NewString()}, wantStatus: http.StatusNotFound},
+		{name: "Only the sender", members: []string{"alice"}, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			social := newSocialDB()
+			ids := map[string]uuid.UUID{
+				"alice": social.addUser(false),
+				"bob":   social.addUser(false),
+				"carol": social.addUser(false),
+			}
+			for _, block := range tt.blocks {
+				social.blocks[userPair{ids[block[0]], ids[block[1]]}] = true
+			}
+			db := newConversationDB(social)
+			if tt.existingDM {
+				existing := uuid.New()
+				db.members[existing] = []uuid.UUID{ids["bob"], ids["alice"]}
+				db.directKeys[directConversationKey(ids["bob"], ids["alice"])] = existing
+			}
+			cfg := &Api{Db: db, InTx: db.tx, JwtTokenSecret: testSecret}
+
+			memberIDs := []string{}
+			for _, member := range tt.members {
+				if id, ok := ids[member]; ok {
+					member = id.String()
+				}
+				memberIDs = append(memberIDs, `"`+member+`"`)
+			}
+			body := `{"member_ids":[` + strings.Join(memberIDs, ",") + `]}`
+
+			rec := httptest.NewRecorder()
+			cfg.handleCreateConversation(rec, newRequest(t, http.MethodPost, "/api/conversations", body, ids["alice"]))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if db.outside != 0 {
+				t.Errorf("%d conversation writes happened outside a transaction", db.outside)
+			}
+			if tt.wantMembers == 0 {
+				return
+			}
+
+			var response ResponseConversation
+			err := json.NewDecoder(rec.Body).Decode(&response)
+			if err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if len(response.MemberIDs) != tt.wantMembers {
+				t.Errorf("members = %v, want %d", response.MemberIDs, tt.wantMembers)
+			}
+		})
+	}
+}
+
+func TestHandleCreateConversationReusesDirect(t *testing.T) {
+	social := newSocialDB()
+	alice := social.addUser(false)
+	bob := social.addUser(false)
+	db := newConversationDB(social)
+	cfg := &Api{Db: db, InTx: db.tx, JwtTokenSecret: testSecret}
+
+	ids := map[uuid.UUID]bool{}
+	for _, pair := range [][2]uuid.UUID{{alice, bob}, {bob, alice}} {
+		rec := httptest.NewRecorder()
+		body := `{"member_ids":["` + pair[1].String() + `"]}`
+		cfg.handleCreateConversation(rec, newRequest(t, http.MethodPost, "/api/conversations", body, pair[0]))
+
+		var response ResponseConversation
+		err := json.NewDecoder(rec.Body).Decode(&response)
+		if err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		ids[uuid.MustParse(response.ID)] = true
+	}
+
+	if len(ids) != 1 || len(db.members) != 1 {
+		t.Errorf("conversations = %d, want one shared direct conversation", len(db.members))
+	}
+}
+
+func TestHandleGetConversations(t *testing.T) {
+	social := newSocialDB()
+	alice := social.addUser(false)
+	bob := social.addUser(false)
+	carol := social.addUser(false)
+	db := newConversationDB(social)
+	db.members[uuid.New()] = []uuid.UUID{alice, bob}
+	db.members[uuid.New()] = []uuid.UUID{alice, carol}
+	db.members[uuid.New()] = []uuid.UUID{alice, bob, carol}
+	db.members[uuid.New()] = []uuid.UUID{bob, carol}
+	cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+	rec := httptest.NewRecorder()
+	cfg.handleGetConversations(rec, newRequest(t, http.MethodGet, "/api/conversations", "", alice))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var response []ResponseConversation
+	err := json.NewDecoder(rec.Body).Decode(&response)
+	if err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(response) != 3 {
+		t.Fatalf("conversations = %d, want 3", len(response))
+	}
+	for _, conversation := range response {
+		if len(conversation.MemberIDs) != len(db.members[uuid.MustParse(conversation.ID)]) {
+			t.Errorf("conversation %s members = %v", conversation.ID, conversation.MemberIDs)
+		}
+	}
+	if db.memberReads != 1 {
+		t.Errorf("member queries = %d, want 1", db.memberReads)
+	}
+}
+
+func TestHandleSendMessageLength(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxLength  int
+		body       string
+		wantStatus int
+	}{
+		{name: "Within plan", maxLength: 140, body: strings.Repeat("a", 140), wantStatus: http.StatusCreated},
+		{name: "Over plan", maxLength: 140, body: strings.Repeat("a", 141), wantStatus: http.StatusBadRequest},
+		{name: "Longer plan", maxLength: 500, body: strings.Repeat("a", 141), wantStatus: http.StatusCreated},
+		{name: "Empty", maxLength: 140, body: "", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			social := newSocialDB()
+			alice := social.addUser(false)
+			bob := social.addUser(false)
+			db := newConversationDB(social)
+			conversationID := uuid.New()
+			db.members[conversationID] = []uuid.UUID{alice, bob}
+			cfg := &Api{
+				Db:             db,
+				JwtTokenSecret: testSecret,
+				Entitlements: resolverFunc(func(ctx context.Context, userID uuid.UUID) (entitlements.Capabilities, error) {
+					return entitlements.Capabilities{MaxChirpLength: tt.maxLength}, nil
+				}),
+			}
+
+			body, err := json.Marshal(RequestMessage{Body: tt.body})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := newRequest(t, http.MethodPost, "/api/conversations/"+conversationID.String()+"/messages", string(body), alice)
+			req.SetPathValue("conversationID", conversationID.String())
+			rec := httptest.NewRecorder()
+			cfg.handleSendMessage(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
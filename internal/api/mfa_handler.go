@@ -0,0 +1,406 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+const (
+	TotpIssuer        = "Chirpy"
+	RecoveryCodeCount = 10
+	// MaxMFAAttempts is how many wrong codes a single MFA token accepts
+	// before the user has to sign in with their password again.
+	MaxMFAAttempts = 5
+	// MaxMFAFailures caps wrong codes per user across all challenges within
+	// MFAFailureWindow, so starting a new login does not reset the budget.
+	MaxMFAFailures = 10
+)
+
+var MFAFailureWindow = time.Minute * 15
+
+// Where a wrong second-factor code was entered, as stored in mfa_failures.
+const (
+	MFAFailureLogin        = "login"
+	MFAFailureOAuthConsent = "oauth_consent"
+)
+
+type ResponseTotpEnroll struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+type RequestTotpCode struct {
+	Code string `json:"code"`
+}
+
+type ResponseRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type RequestDisableTotp struct {
+	Password string `json:"password"`
+}
+
+type RequestLoginMFA struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func (cfg *Api) handleEnrollTotp(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if user.TotpEnabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+
+	err = cfg.Db.SetUserTotpSecret(r.Context(), database.SetUserTotpSecretParams{
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+		ID:         user.ID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to save secret", http.StatusInternalServerError)
+		return
+	}
+
+	response := ResponseTotpEnroll{
+		Secret:     secret,
+		OtpauthURI: auth.TOTPURI(TotpIssuer, user.Email, secret),
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleConfirmTotp(w http.ResponseWriter, r *http.Request) {
+	var request RequestTotpCode
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if user.TotpEnabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	if !user.TotpSecret.Valid {
+		http.Error(w, "Two-factor enrollment has not been started", http.StatusBadRequest)
+		return
+	}
+
+	ok, err := cfg.useTOTPCode(r.Context(), user, request.Code)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error recording TOTP step", "error", err)
+		http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	codes, err := auth.MakeRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		http.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	err = cfg.Db.DeleteRecoveryCodesForUser(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "Failed to save recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	for _, code := range codes {
+		err = cfg.Db.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashRecoveryCode(code),
+		})
+		if err != nil {
+			http.Error(w, "Failed to save recovery codes", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = cfg.Db.EnableUserTotp(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	response := ResponseRecoveryCodes{
+		RecoveryCodes: codes,
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleDisableTotp(w http.ResponseWriter, r *http.Request) {
+	var request RequestDisableTotp
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	err = auth.CheckPasswordHash(request.Password, user.HashedPassword)
+	if err != nil {
+		http.Error(w, "Invalid password", http.StatusUnauthorized)
+		return
+	}
+
+	err = cfg.Db.DisableUserTotp(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	err = cfg.Db.DeleteRecoveryCodesForUser(r.Context(), user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error deleting recovery codes", "error", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *Api) handleLoginMFA(w http.ResponseWriter, r *http.Request) {
+	var request RequestLoginMFA
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, challengeID, err := auth.ValidateMFAToken(request.MFAToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Invalid or expired MFA token", http.StatusUnauthorized)
+		return
+	}
+
+	challenge, err := cfg.Db.GetMFAChallengeByID(r.Context(), challengeID)
+	if err != nil || challenge.UserID != userID || challenge.UsedAt.Valid || challenge.ExpiresAt.Before(time.Now()) {
+		http.Error(w, "Invalid or expired MFA token", http.StatusUnauthorized)
+		return
+	}
+
+	if challenge.FailedAttempts >= MaxMFAAttempts {
+		http.Error(w, "Too many failed attempts, sign in again", http.StatusTooManyRequests)
+		return
+	}
+
+	locked, err := cfg.mfaLockedOut(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error counting MFA failures", "error", err)
+		http.Error(w, "Failed to check code", http.StatusInternalServerError)
+		return
+	}
+	if locked {
+		http.Error(w, "Too many failed two-factor attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if !user.TotpEnabled || !user.TotpSecret.Valid {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case request.Code != "":
+		ok, err := cfg.useTOTPCode(r.Context(), user, request.Code)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error recording TOTP step", "error", err)
+			http.Error(w, "Failed to check code", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			cfg.recordMFAFailure(r.Context(), challenge)
+			http.Error(w, "Invalid code", http.StatusUnauthorized)
+			return
+		}
+	case request.RecoveryCode != "":
+		used, err := cfg.Db.UseRecoveryCode(r.Context(), database.UseRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashRecoveryCode(request.RecoveryCode),
+		})
+		if err != nil {
+			http.Error(w, "Failed to check recovery code", http.StatusInternalServerError)
+			return
+		}
+		if used == 0 {
+			cfg.recordMFAFailure(r.Context(), challenge)
+			http.Error(w, "Invalid recovery code", http.StatusUnauthorized)
+			return
+		}
+	default:
+		http.Error(w, "Code or recovery code is required", http.StatusBadRequest)
+		return
+	}
+
+	// Redeeming the challenge only after the code checks out keeps wrong
+	// guesses countable, while two requests racing with the same token still
+	// cannot both sign in.
+	claimed, err := cfg.Db.UseMFAChallenge(r.Context(), challenge.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error redeeming MFA challenge", "error", err)
+		http.Error(w, "Failed to check code", http.StatusInternalServerError)
+		return
+	}
+	if claimed == 0 {
+		http.Error(w, "Invalid or expired MFA token", http.StatusUnauthorized)
+		return
+	}
+
+	cfg.completeLogin(w, r, user)
+}
+
+// useTOTPCode checks a TOTP code and records the time step it matched. A code
+// from a step at or below the last accepted one is rejected, so each code
+// works once even within its validity window.
+func (cfg *Api) useTOTPCode(ctx context.Context, user database.User, code string) (bool, error) {
+	step, ok := auth.MatchTOTP(code, user.TotpSecret.String, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	used, err := cfg.Db.UseTotpStep(ctx, database.UseTotpStepParams{
+		TotpLastStep: step,
+		ID:           user.ID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return used > 0, nil
+}
+
+// mfaLockedOut reports whether the user has run out of wrong second-factor
+// codes for the current MFAFailureWindow.
+func (cfg *Api) mfaLockedOut(ctx context.Context, userID uuid.UUID) (bool, error) {
+	failures, err := cfg.Db.CountRecentMFAFailures(ctx, database.CountRecentMFAFailuresParams{
+		UserID:    userID,
+		CreatedAt: time.Now().Add(-MFAFailureWindow),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return failures >= MaxMFAFailures, nil
+}
+
+// recordMFAFailure counts a wrong code entered for a login challenge, both
+// against the challenge and against the user.
+func (cfg *Api) recordMFAFailure(ctx context.Context, challenge database.MfaChallenge) {
+	err := cfg.Db.RecordMFAChallengeFailure(ctx, challenge.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "error recording MFA failure", "error", err)
+	}
+
+	cfg.recordUserMFAFailure(ctx, challenge.UserID, MFAFailureLogin)
+}
+
+// recordConsentMFAFailure counts a wrong code entered on the OAuth consent
+// page. The consent form has no challenge, so only the per-user limit moves.
+func (cfg *Api) recordConsentMFAFailure(ctx context.Context, userID uuid.UUID) {
+	cfg.recordUserMFAFailure(ctx, userID, MFAFailureOAuthConsent)
+}
+
+func (cfg *Api) recordUserMFAFailure(ctx context.Context, userID uuid.UUID, source string) {
+	cfg.Metrics.LoginFailed("invalid_mfa_code")
+
+	err := cfg.Db.CreateMFAFailure(ctx, database.CreateMFAFailureParams{
+		UserID: userID,
+		Source: source,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "error recording MFA failure", "error", err)
+	}
+}
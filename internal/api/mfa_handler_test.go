@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+// mfaDB keeps MFA challenges and the last accepted TOTP step in memory.
+type mfaDB struct {
+	accountDB
+	challenges map[uuid.UUID]*database.MfaChallenge
+	failures   []database.MfaFailure
+}
+
+func (db *mfaDB) CreateMFAChallenge(ctx context.Context, arg database.CreateMFAChallengeParams) (database.MfaChallenge, error) {
+	challenge := database.MfaChallenge{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		CreatedAt: time.Now(),
+		ExpiresAt: arg.ExpiresAt,
+	}
+	db.challenges[challenge.ID] = &challenge
+	return challenge, nil
+}
+
+func (db *mfaDB) GetMFAChallengeByID(ctx context.Context, id uuid.UUID) (database.MfaChallenge, error) {
+	challenge, ok := db.challenges[id]
+	if !ok {
+		return database.MfaChallenge{}, sql.ErrNoRows
+	}
+	return *challenge, nil
+}
+
+func (db *mfaDB) RecordMFAChallengeFailure(ctx context.Context, id uuid.UUID) error {
+	db.challenges[id].FailedAttempts++
+	return nil
+}
+
+func (db *mfaDB) UseMFAChallenge(ctx context.Context, id uuid.UUID) (int64, error) {
+	challenge := db.challenges[id]
+	if challenge.UsedAt.Valid {
+		return 0, nil
+	}
+	challenge.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return 1, nil
+}
+
+func (db *mfaDB) CreateMFAFailure(ctx context.Context, arg database.CreateMFAFailureParams) error {
+	db.failures = append(db.failures, database.MfaFailure{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		Source:    arg.Source,
+		CreatedAt: time.Now(),
+	})
+	return nil
+}
+
+func (db *mfaDB) CountRecentMFAFailures(ctx context.Context, arg database.CountRecentMFAFailuresParams) (int32, error) {
+	var failures int32
+	for _, failure := range db.failures {
+		if failure.UserID == arg.UserID && failure.CreatedAt.After(arg.CreatedAt) {
+			failures++
+		}
+	}
+	return failures, nil
+}
+
+func (db *mfaDB) UseTotpStep(ctx context.Context, arg database.UseTotpStepParams) (int64, error) {
+	if arg.TotpLastStep <= db.user.TotpLastStep {
+		return 0, nil
+	}
+	db.user.TotpLastStep = arg.TotpLastStep
+	return 1, nil
+}
+
+func newMFADB(t *testing.T) *mfaDB {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+
+	db := &mfaDB{accountDB: *newAccountDB(t), challenges: map[uuid.UUID]*database.MfaChallenge{}}
+	db.user.TotpEnabled = true
+	db.user.TotpSecret = sql.NullString{String: secret, Valid: true}
+	return db
+}
+
+// currentTOTP computes the code an authenticator app would show right now.
+func currentTOTP(t *testing.T, secret string) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("invalid secret: %v", err)
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+func startMFALogin(t *testing.T, cfg *Api, db *mfaDB) string {
+	t.Helper()
+
+	challenge, err := db.CreateMFAChallenge(context.Background(), database.CreateMFAChallengeParams{
+		UserID:    db.user.ID,
+		ExpiresAt: time.Now().Add(auth.MFAChallengeDuration),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.MakeMFAToken(db.user.ID, challenge.ID, cfg.JwtTokenSecret)
+	if err != nil {
+		t.Fatalf("MakeMFAToken() error = %v", err)
+	}
+	return token
+}
+
+func loginMFA(t *testing.T, cfg *Api, mfaToken, code string) int {
+	rec := httptest.NewRecorder()
+	body := fmt.Sprintf(`{"mfa_token":%q,"code":%q}`, mfaToken, code)
+	cfg.handleLoginMFA(rec, newRequest(t, http.MethodPost, "/api/login/2fa", body, uuid.Nil))
+	return rec.Code
+}
+
+func TestHandleLoginMFA(t *testing.T) {
+	t.Run("Accepts a valid code", func(t *testing.T) {
+		db := newMFADB(t)
+		cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+		if got := loginMFA(t, cfg, startMFALogin(t, cfg, db), currentTOTP(t, db.user.TotpSecret.String)); got != http.StatusOK {
+			t.Errorf("status = %d, want %d", got, http.StatusOK)
+		}
+	})
+
+	t.Run("Token is single-use", func(t *testing.T) {
+		db := newMFADB(t)
+		cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+		token := startMFALogin(t, cfg, db)
+
+		if got := loginMFA(t, cfg, token, currentTOTP(t, db.user.TotpSecret.String)); got != http.StatusOK {
+			t.Fatalf("first login status = %d, want %d", got, http.StatusOK)
+		}
+		db.user.TotpLastStep = 0
+		if got := loginMFA(t, cfg, token, currentTOTP(t, db.user.TotpSecret.String)); got != http.StatusUnauthorized {
+			t.Errorf("reused token status = %d, want %d", got, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("Rejects a replayed code", func(t *testing.T) {
+		db := newMFADB(t)
+		cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+		code := currentTOTP(t, db.user.TotpSecret.String)
+
+		if got := loginMFA(t, cfg, startMFALogin(t, cfg, db), code); got != http.StatusOK {
+			t.Fatalf("first login status = %d, want %d", got, http.StatusOK)
+		}
+		if got := loginMFA(t, cfg, startMFALogin(t, cfg, db), code); got != http.StatusUnauthorized {
+			t.Errorf("replayed code status = %d, want %d", got, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("Locks the token after too many wrong codes", func(t *testing.T) {
+		db := newMFADB(t)
+		cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+		token := startMFALogin(t, cfg, db)
+
+		for range MaxMFAAttempts {
+			if got := loginMFA(t, cfg, token, "000000"); got != http.StatusUnauthorized {
+				t.Fatalf("wrong code status = %d, want %d", got, http.StatusUnauthorized)
+			}
+		}
+		if got := loginMFA(t, cfg, token, currentTOTP(t, db.user.TotpSecret.String)); got != http.StatusTooManyRequests {
+			t.Errorf("status after lockout = %d, want %d", got, http.StatusTooManyRequests)
+		}
+	})
+
+	t.Run("Locks the user across challenges", func(t *testing.T) {
+		db := newMFADB(t)
+		cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+		for failures := 0; failures < MaxMFAFailures; {
+			token := startMFALogin(t, cfg, db)
+			for range MaxMFAAttempts {
+				loginMFA(t, cfg, token, "000000")
+				failures++
+			}
+		}
+
+		rec := httptest.NewRecorder()
+		cfg.handleLogin(rec, newRequest(t, http.MethodPost, "/api/login", `{"email":"walt@example.com","password":"04234"}`, uuid.Nil))
+		if rec.Code != http.StatusTooManyRequests {
+			t.Errorf("password login status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+		}
+	})
+
+	t.Run("Consent failures count without a challenge", func(t *testing.T) {
+		db := newMFADB(t)
+		cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+		for range MaxMFAFailures {
+			cfg.recordConsentMFAFailure(context.Background(), db.user.ID)
+		}
+
+		if len(db.challenges) != 0 {
+			t.Errorf("challenges = %d, want none", len(db.challenges))
+		}
+		if got := loginMFA(t, cfg, startMFALogin(t, cfg, db), currentTOTP(t, db.user.TotpSecret.String)); got != http.StatusTooManyRequests {
+			t.Errorf("status after consent failures = %d, want %d", got, http.StatusTooManyRequests)
+		}
+	})
+}
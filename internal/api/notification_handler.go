@@ -0,0 +1,347 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+	"github.com/joaogiacometti/goserver/internal/stream"
+)
+
+const (
+	NotificationFollow         = "follow"
+	NotificationFollowRequest  = "follow_request"
+	NotificationFollowAccepted = "follow_accepted"
+	NotificationMention        = "mention"
+)
+
+// NotificationTypes lists every notification a user can switch off. Types
+// missing from a user's preferences are enabled.
+var NotificationTypes = []string{
+	NotificationFollow,
+	NotificationFollowRequest,
+	NotificationFollowAccepted,
+	NotificationMention,
+}
+
+type ResponseNotification struct {
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	ActorID   *string    `json:"actor_id"`
+	SubjectID *string    `json:"subject_id"`
+	Read      bool       `json:"read"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type ResponseUnreadCount struct {
+	Count int64 `json:"count"`
+}
+
+func mapNotificationToResponse(notification database.Notification) ResponseNotification {
+	response := ResponseNotification{
+		ID:        notification.ID.String(),
+		Type:      notification.Type,
+		Read:      notification.ReadAt.Valid,
+		CreatedAt: notification.CreatedAt,
+	}
+	if notification.ActorID.Valid {
+		actorID := notification.ActorID.UUID.String()
+		response.ActorID = &actorID
+	}
+	if notification.SubjectID.Valid {
+		subjectID := notification.SubjectID.UUID.String()
+		response.SubjectID = &subjectID
+	}
+	if notification.ReadAt.Valid {
+		response.ReadAt = &notification.ReadAt.Time
+	}
+	return response
+}
+
+// notificationPreferences returns the effective setting for every type.
+func notificationPreferences(user database.User) map[string]bool {
+	stored := map[string]bool{}
+	if len(user.NotificationPreferences) > 0 {
+		err := json.Unmarshal(user.NotificationPreferences, &stored)
+		if err != nil {
+			slog.Error("error decoding notification preferences", "user_id", user.ID, "error", err)
+		}
+	}
+
+	preferences := map[string]bool{}
+	for _, notificationType := range NotificationTypes {
+		enabled, ok := stored[notificationType]
+		preferences[notificationType] = !ok || enabled
+	}
+	return preferences
+}
+
+// notify records a notification for userID unless they have switched that
+// type off, and pushes it to their live connections. Failures are logged
+// rather than surfaced, since the action that triggered it has succeeded.
+func (cfg *Api) notify(ctx context.Context, userID, actorID uuid.UUID, notificationType string, subjectID uuid.NullUUID) {
+	user, err := cfg.Db.GetUserByID(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "error loading notification recipient", "error", err)
+		return
+	}
+
+	if !notificationPreferences(user)[notificationType] {
+		return
+	}
+
+	notification, err := cfg.Db.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:    userID,
+		ActorID:   uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil},
+		Type:      notificationType,
+		SubjectID: subjectID,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "error creating notification", "error", err)
+		return
+	}
+
+	if cfg.NotificationStream == nil {
+		return
+	}
+
+	data, err := json.Marshal(mapNotificationToResponse(notification))
+	if err != nil {
+		slog.ErrorContext(ctx, "error encoding notification event", "error", err)
+		return
+	}
+
+	cfg.NotificationStream.Publish(stream.Event{
+		Type:   "notification." + notificationType,
+		UserID: actorID,
+		Topic:  userID,
+		Data:   data,
+	})
+}
+
+func (cfg *Api) handleGetNotifications(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	notifications, err := cfg.Db.GetNotifications(r.Context(), database.GetNotificationsParams{
+		UserID:  userID,
+		Column2: r.URL.Query().Get("unread") == "true",
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error retrieving notifications", "error", err)
+		http.Error(w, "Failed to retrieve notifications", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponseNotification{}
+	for _, notification := range notifications {
+		response = append(response, mapNotificationToResponse(notification))
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleGetUnreadNotificationCount(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := cfg.Db.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to count notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(ResponseUnreadCount{Count: count})
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	notificationID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := cfg.Db.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: userID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to mark notification as read", http.StatusInternalServerError)
+		return
+	}
+	if rows == 0 {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *Api) handleMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	_, err = cfg.Db.MarkAllNotificationsRead(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to mark notifications as read", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *Api) handleGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(notificationPreferences(user))
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleUpdateNotificationPreferences merges the given types into the stored
+// preferences, so clients may send only the settings they change.
+func (cfg *Api) handleUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	var request map[string]bool
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	preferences := notificationPreferences(user)
+	for notificationType, enabled := range request {
+		if _, ok := preferences[notificationType]; !ok {
+			http.Error(w, fmt.Sprintf("Unknown notification type: %s", notificationType), http.StatusBadRequest)
+			return
+		}
+		preferences[notificationType] = enabled
+	}
+
+	encoded, err := json.Marshal(preferences)
+	if err != nil {
+		http.Error(w, "Failed to encode preferences", http.StatusInternalServerError)
+		return
+	}
+
+	user, err = cfg.Db.SetNotificationPreferences(r.Context(), database.SetNotificationPreferencesParams{
+		NotificationPreferences: encoded,
+		ID:                      userID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to update preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(notificationPreferences(user))
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
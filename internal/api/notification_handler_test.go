@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+// notificationDB stores notifications and preferences for the users of a
+// socialDB.
+type notificationDB struct {
+	*socialDB
+	notifications []database.Notification
+}
+
+func newNotificationDB() *notificationDB {
+	return &notificationDB{socialDB: newSocialDB()}
+}
+
+func (db *notificationDB) addUserWithEmail(email string, private bool) uuid.UUID {
+	id := db.addUser(private)
+	user := db.users[id]
+	user.Email = email
+	db.users[id] = user
+	return id
+}
+
+func (db *notificationDB) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	for _, user := range db.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (db *notificationDB) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error) {
+	notification := database.Notification{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		ActorID:   arg.ActorID,
+		Type:      arg.Type,
+		SubjectID: arg.SubjectID,
+		CreatedAt: time.Now(),
+	}
+	db.notifications = append(db.notifications, notification)
+	return notification, nil
+}
+
+func (db *notificationDB) GetNotifications(ctx context.Context, arg database.GetNotificationsParams) ([]database.Notification, error) {
+	notifications := []database.Notification{}
+	for _, notification := range db.notifications {
+		if notification.UserID != arg.UserID || (arg.Column2 && notification.ReadAt.Valid) {
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, nil
+}
+
+func (db *notificationDB) SetNotificationPreferences(ctx context.Context, arg database.SetNotificationPreferencesParams) (database.User, error) {
+	user, ok := db.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	user.NotificationPreferences = arg.NotificationPreferences
+	db.users[arg.ID] = user
+	return user, nil
+}
+
+func TestHandleUpdateNotificationPreferences(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     string
+		body       string
+		wantStatus int
+		want       map[string]bool
+	}{
+		{
+			name:       "Keeps settings missing from the request",
+			stored:     `{"follow":false}`,
+			body:       `{"mention":false}`,
+			wantStatus: http.StatusOK,
+			want:       map[string]bool{NotificationFollow: false, NotificationFollowRequest: true, NotificationFollowAccepted: true, NotificationMention: false},
+		},
+		{
+			name:       "Overrides a stored setting",
+			stored:     `{"follow":false}`,
+			body:       `{"follow":true}`,
+			wantStatus: http.StatusOK,
+			want:       map[string]bool{NotificationFollow: true, NotificationFollowRequest: true, NotificationFollowAccepted: true, NotificationMention: true},
+		},
+		{
+			name:       "Unknown type",
+			stored:     `{"follow":false}`,
+			body:       `{"digest":false}`,
+			wantStatus: http.StatusBadRequest,
+			want:       map[string]bool{NotificationFollow: false, NotificationFollowRequest: true, NotificationFollowAccepted: true, NotificationMention: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newNotificationDB()
+			userID := db.addUser(false)
+			user := db.users[userID]
+			user.NotificationPreferences = json.RawMessage(tt.stored)
+			db.users[userID] = user
+
+			rec := httptest.NewRecorder()
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+			cfg.handleUpdateNotificationPreferences(rec, newRequest(t, http.MethodPut, "/api/users/notification-preferences", tt.body, userID))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			got := notificationPreferences(db.users[userID])
+			for notificationType, enabled := range tt.want {
+				if got[notificationType] != enabled {
+					t.Errorf("%s = %v, want %v", notificationType, got[notificationType], enabled)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleGetNotificationsUnread(t *testing.T) {
+	db := newNotificationDB()
+	userID := db.addUser(false)
+	db.notifications = []database.Notification{
+		{ID: uuid.New(), UserID: userID, Type: NotificationFollow},
+		{ID: uuid.New(), UserID: userID, Type: NotificationFollow, ReadAt: sql.NullTime{Time: time.Now(), Valid: true}},
+		{ID: uuid.New(), UserID: uuid.New(), Type: NotificationFollow},
+	}
+	cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{name: "All", target: "/api/notifications", want: 2},
+		{name: "Unread only", target: "/api/notifications?unread=true", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			cfg.handleGetNotifications(rec, newRequest(t, http.MethodGet, tt.target, "", userID))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			var response []ResponseNotification
+			err := json.NewDecoder(rec.Body).Decode(&response)
+			if err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if len(response) != tt.want {
+				t.Fatalf("got %d notifications, want %d", len(response), tt.want)
+			}
+			for _, notification := range response {
+				if strings.Contains(tt.target, "unread") && notification.Read {
+					t.Errorf("unread filter returned read notification %s", notification.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestNotifyMentions(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		privateAuthor bool
+		blocked       bool
+		mutedType     bool
+		wantNotified  bool
+	}{
+		{name: "Mentioned", body: "hi @walt@example.com!", wantNotified: true},
+		{name: "No mention", body: "hi walt@example.com", wantNotified: false},
+		{name: "Unknown account", body: "hi @jesse@example.com", wantNotified: false},
+		{name: "Blocked", body: "hi @walt@example.com", blocked: true, wantNotified: false},
+		{name: "Cannot see the chirp", body: "hi @walt@example.com", privateAuthor: true, wantNotified: false},
+		{name: "Mentions switched off", body: "hi @walt@example.com", mutedType: true, wantNotified: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newNotificationDB()
+			authorID := db.addUserWithEmail("skyler@example.com", tt.privateAuthor)
+			mentionedID := db.addUserWithEmail("walt@example.com", false)
+			if tt.blocked {
+				db.blocks[userPair{mentionedID, authorID}] = true
+			}
+			if tt.mutedType {
+				user := db.users[mentionedID]
+				user.NotificationPreferences = json.RawMessage(`{"mention":false}`)
+				db.users[mentionedID] = user
+			}
+
+			chirp := database.Chirp{ID: uuid.New(), Body: tt.body, UserID: authorID}
+			cfg := &Api{Db: db}
+			cfg.notifyMentions(context.Background(), chirp)
+
+			if got := len(db.notifications) == 1; got != tt.wantNotified {
+				t.Fatalf("notifications = %+v, want notified %v", db.notifications, tt.wantNotified)
+			}
+			if !tt.wantNotified {
+				return
+			}
+			notification := db.notifications[0]
+			if notification.UserID != mentionedID || notification.Type != NotificationMention || notification.SubjectID.UUID != chirp.ID {
+				t.Errorf("notification = %+v", notification)
+			}
+		})
+	}
+}
+
+func TestNotifyMentionsSkipsAuthor(t *testing.T) {
+	db := newNotificationDB()
+	authorID := db.addUserWithEmail("walt@example.com", false)
+
+	cfg := &Api{Db: db}
+	cfg.notifyMentions(context.Background(), database.Chirp{ID: uuid.New(), Body: "note to self @walt@example.com", UserID: authorID})
+
+	if len(db.notifications) != 0 {
+		t.Errorf("author was notified of their own mention: %+v", db.notifications)
+	}
+}
@@ -0,0 +1,719 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+const OAuthCodeDuration = time.Minute * 5
+
+type RequestOAuthClient struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Confidential bool     `json:"confidential"`
+}
+
+type ResponseOAuthClient struct {
+	ClientID     string    `json:"client_id"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ResponseOAuthToken struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+type ResponseIntrospection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+}
+
+type ResponseOAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type ResponseUserInfo struct {
+	Subject     string `json:"sub"`
+	Email       string `json:"email"`
+	IsChirpyRed bool   `json:"is_chirpy_red"`
+}
+
+// authorizeRequest is an authorization request whose client and redirect URI
+// have been checked, so errors can be reported back to the client.
+type authorizeRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	Scope         string
+	State         string
+	CodeChallenge string
+}
+
+type consentScope struct {
+	Name        string
+	Description string
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`
+	<html>
+		<body>
+			<h1>Authorize {{.ClientName}}</h1>
+			<p>{{.ClientName}} would like to:</p>
+			<ul>
+				{{range .Scopes}}<li>{{.Description}} ({{.Name}})</li>{{end}}
+			</ul>
+			{{if .Error}}<p>{{.Error}}</p>{{end}}
+			<form method="POST" action="/oauth/authorize">
+				<input type="hidden" name="response_type" value="code">
+				<input type="hidden" name="client_id" value="{{.ClientID}}">
+				<input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+				<input type="hidden" name="scope" value="{{.Scope}}">
+				<input type="hidden" name="state" value="{{.State}}">
+				<input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+				<input type="hidden" name="code_challenge_method" value="S256">
+				<p><input type="email" name="email" placeholder="Email"></p>
+				<p><input type="password" name="password" placeholder="Password"></p>
+				<p><input type="text" name="code" placeholder="Two-factor code (if enabled)"></p>
+				<button type="submit" name="decision" value="allow">Allow</button>
+				<button type="submit" name="decision" value="deny">Deny</button>
+			</form>
+		</body>
+	</html>`))
+
+func (cfg *Api) handleCreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	var request RequestOAuthClient
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.Name == "" || len(request.RedirectURIs) == 0 {
+		http.Error(w, "Name and at least one redirect URI are required", http.StatusBadRequest)
+		return
+	}
+
+	for _, redirectURI := range request.RedirectURIs {
+		parsed, err := url.Parse(redirectURI)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			http.Error(w, "Invalid redirect URI", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var secret string
+	var secretHash sql.NullString
+	if request.Confidential {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			http.Error(w, "Failed to create client secret", http.StatusInternalServerError)
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, err := cfg.Db.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		OwnerID:      userID,
+		Name:         request.Name,
+		SecretHash:   secretHash,
+		RedirectUris: request.RedirectURIs,
+	})
+	if err != nil {
+		http.Error(w, "Failed to create client", http.StatusInternalServerError)
+		return
+	}
+
+	response := mapOAuthClientToResponse(client)
+	response.ClientSecret = secret
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleGetOAuthClients(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	clients, err := cfg.Db.GetOAuthClientsByOwner(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve clients", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponseOAuthClient{}
+	for _, client := range clients {
+		response = append(response, mapOAuthClientToResponse(client))
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleDeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		http.Error(w, "Invalid client ID", http.StatusBadRequest)
+		return
+	}
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deleted, err := cfg.Db.DeleteOAuthClient(r.Context(), database.DeleteOAuthClientParams{
+		ID:      clientID,
+		OwnerID: userID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete client", http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		http.Error(w, "Client not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func mapOAuthClientToResponse(client database.OauthClient) ResponseOAuthClient {
+	return ResponseOAuthClient{
+		ClientID:     client.ID.String(),
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Confidential: client.SecretHash.Valid,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// parseAuthorizeRequest validates the client and redirect URI first; until
+// both are known to be good, errors must not be redirected anywhere.
+func (cfg *Api) parseAuthorizeRequest(r *http.Request, values url.Values) (authorizeRequest, string, error) {
+	var request authorizeRequest
+
+	clientID, err := uuid.Parse(values.Get("client_id"))
+	if err != nil {
+		return request, "", errors.New("invalid client_id")
+	}
+
+	request.Client, err = cfg.Db.GetOAuthClientByID(r.Context(), clientID)
+	if err != nil {
+		return request, "", errors.New("unknown client")
+	}
+
+	request.RedirectURI = values.Get("redirect_uri")
+	if !slices.Contains(request.Client.RedirectUris, request.RedirectURI) {
+		return request, "", errors.New("redirect_uri is not registered for this client")
+	}
+
+	request.State = values.Get("state")
+
+	if values.Get("response_type") != "code" {
+		return request, "unsupported_response_type", nil
+	}
+
+	request.CodeChallenge = values.Get("code_challenge")
+	if request.CodeChallenge == "" || values.Get("code_challenge_method") != "S256" {
+		return request, "invalid_request", nil
+	}
+
+	request.Scope, err = auth.NormalizeScope(values.Get("scope"))
+	if err != nil {
+		return request, "invalid_scope", nil
+	}
+
+	return request, "", nil
+}
+
+func redirectWithOAuthParams(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "Invalid redirect URI", http.StatusBadRequest)
+		return
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	target.RawQuery = query.Encode()
+
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func renderConsent(w http.ResponseWriter, request authorizeRequest, status int, message string) {
+	var scopes []consentScope
+	for _, scope := range strings.Fields(request.Scope) {
+		scopes = append(scopes, consentScope{Name: scope, Description: auth.OAuthScopes[scope]})
+	}
+
+	// The consent form takes the user's password, so it must never render
+	// inside another site's frame.
+	w.Header().Set("x-frame-options", "DENY")
+	w.Header().Set("content-security-policy", "frame-ancestors 'none'")
+	w.Header().Set("content-type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	consentTemplate.Execute(w, map[string]any{
+		"ClientName":    request.Client.Name,
+		"ClientID":      request.Client.ID.String(),
+		"RedirectURI":   request.RedirectURI,
+		"Scope":         request.Scope,
+		"Scopes":        scopes,
+		"State":         request.State,
+		"CodeChallenge": request.CodeChallenge,
+		"Error":         message,
+	})
+}
+
+func (cfg *Api) handleOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	request, oauthErr, err := cfg.parseAuthorizeRequest(r, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if oauthErr != "" {
+		redirectWithOAuthParams(w, r, request.RedirectURI, url.Values{"error": {oauthErr}, "state": {request.State}})
+		return
+	}
+
+	renderConsent(w, request, http.StatusOK, "")
+}
+
+func (cfg *Api) handleOAuthConsent(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	request, oauthErr, err := cfg.parseAuthorizeRequest(r, r.PostForm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if oauthErr != "" {
+		redirectWithOAuthParams(w, r, request.RedirectURI, url.Values{"error": {oauthErr}, "state": {request.State}})
+		return
+	}
+
+	if r.PostForm.Get("decision") != "allow" {
+		redirectWithOAuthParams(w, r, request.RedirectURI, url.Values{"error": {"access_denied"}, "state": {request.State}})
+		return
+	}
+
+	user, err := cfg.Db.GetUserByEmail(r.Context(), r.PostForm.Get("email"))
+	if err == nil {
+		err = auth.CheckPasswordHash(r.PostForm.Get("password"), user.HashedPassword)
+	}
+	if err != nil {
+		renderConsent(w, request, http.StatusUnauthorized, "Invalid email or password")
+		return
+	}
+
+	if user.TotpEnabled {
+		locked, err := cfg.mfaLockedOut(r.Context(), user.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error counting MFA failures", "error", err)
+			http.Error(w, "Failed to check two-factor code", http.StatusInternalServerError)
+			return
+		}
+		if locked {
+			renderConsent(w, request, http.StatusTooManyRequests, "Too many failed two-factor attempts, try again later")
+			return
+		}
+
+		ok, err := cfg.useTOTPCode(r.Context(), user, r.PostForm.Get("code"))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error recording TOTP step", "error", err)
+			http.Error(w, "Failed to check two-factor code", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			cfg.recordConsentMFAFailure(r.Context(), user.ID)
+			renderConsent(w, request, http.StatusUnauthorized, "Invalid two-factor code")
+			return
+		}
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		http.Error(w, "Failed to create authorization code", http.StatusInternalServerError)
+		return
+	}
+
+	err = cfg.Db.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      request.Client.ID,
+		UserID:        user.ID,
+		RedirectUri:   request.RedirectURI,
+		Scope:         request.Scope,
+		CodeChallenge: request.CodeChallenge,
+		ExpiresAt:     time.Now().Add(OAuthCodeDuration),
+	})
+	if err != nil {
+		http.Error(w, "Failed to create authorization code", http.StatusInternalServerError)
+		return
+	}
+
+	redirectWithOAuthParams(w, r, request.RedirectURI, url.Values{"code": {code}, "state": {request.State}})
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("cache-control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ResponseOAuthError{
+		Error:            code,
+		ErrorDescription: description,
+	})
+}
+
+// authenticateOAuthClient identifies the calling client from HTTP Basic
+// credentials or form fields. Public clients only need to send their ID.
+func (cfg *Api) authenticateOAuthClient(r *http.Request) (database.OauthClient, error) {
+	clientIDString, secret, ok := r.BasicAuth()
+	if !ok {
+		clientIDString = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	clientID, err := uuid.Parse(clientIDString)
+	if err != nil {
+		return database.OauthClient{}, errors.New("invalid client_id")
+	}
+
+	client, err := cfg.Db.GetOAuthClientByID(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, errors.New("unknown client")
+	}
+
+	if client.SecretHash.Valid {
+		hash := auth.HashToken(secret)
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash.String)) != 1 {
+			return database.OauthClient{}, errors.New("invalid client secret")
+		}
+	}
+
+	return client, nil
+}
+
+var errRefreshTokenUsed = errors.New("refresh token already used")
+
+func (cfg *Api) issueClientRefreshToken(ctx context.Context, q database.Querier, clientID, userID uuid.UUID, scope string) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = q.CreateClientRefreshToken(ctx, database.CreateClientRefreshTokenParams{
+		Token:     refreshToken,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(cfg.refreshTokenTTL()),
+		ClientID:  uuid.NullUUID{UUID: clientID, Valid: true},
+		Scope:     sql.NullString{String: scope, Valid: true},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return refreshToken, nil
+}
+
+func (cfg *Api) handleOAuthToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	var userID uuid.UUID
+	var scope string
+	var refreshToken string
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, err := cfg.Db.TakeOAuthAuthorizationCode(r.Context(), auth.HashToken(r.PostForm.Get("code")))
+		if err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid or expired authorization code")
+			return
+		}
+
+		if code.ClientID != client.ID || code.RedirectUri != r.PostForm.Get("redirect_uri") {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Authorization code was issued to another client or redirect URI")
+			return
+		}
+
+		if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid code verifier")
+			return
+		}
+
+		userID = code.UserID
+		scope = code.Scope
+
+		refreshToken, err = cfg.issueClientRefreshToken(r.Context(), cfg.Db, client.ID, userID, scope)
+		if err != nil {
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "Failed to create refresh token")
+			return
+		}
+	case "refresh_token":
+		presented := r.PostForm.Get("refresh_token")
+		token, err := cfg.Db.GetRefreshTokenByToken(r.Context(), presented)
+		if err != nil || token.ClientID.UUID != client.ID || token.RevokedAt.Valid || token.ExpiresAt.Before(time.Now()) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid or expired refresh token")
+			return
+		}
+
+		userID = token.UserID
+		scope = token.Scope.String
+
+		// Each refresh token works once. Claiming it and issuing its
+		// replacement happen together, so two requests racing with the same
+		// token cannot both get a new one.
+		err = cfg.InTx(r.Context(), func(q database.Querier) error {
+			claimed, err := q.ClaimClientRefreshToken(r.Context(), database.ClaimClientRefreshTokenParams{
+				Token:    presented,
+				ClientID: uuid.NullUUID{UUID: client.ID, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+			if claimed == 0 {
+				return errRefreshTokenUsed
+			}
+
+			refreshToken, err = cfg.issueClientRefreshToken(r.Context(), q, client.ID, userID, scope)
+			return err
+		})
+		if errors.Is(err, errRefreshTokenUsed) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid or expired refresh token")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rotating refresh token", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "Failed to create refresh token")
+			return
+		}
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(r.Context(), userID)
+	if err != nil || accountRestriction(user) != "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Account is not active")
+		return
+	}
+
+	accessToken, err := auth.MakeScopedJWT(userID, cfg.JwtTokenSecret, client.ID, scope, cfg.accessTokenTTL())
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "Failed to create token")
+		return
+	}
+
+	response := ResponseOAuthToken{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(cfg.accessTokenTTL().Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        scope,
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("cache-control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleOAuthIntrospect implements RFC 7662. A client only learns about
+// tokens that were issued to it; anything else is reported as inactive.
+func (cfg *Api) handleOAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	response := ResponseIntrospection{Active: false}
+
+	if claims, err := auth.ParseAccessToken(token, cfg.JwtTokenSecret); err == nil {
+		if claims.ClientID == client.ID.String() {
+			response = ResponseIntrospection{
+				Active:    true,
+				Scope:     claims.Scope,
+				ClientID:  claims.ClientID,
+				Subject:   claims.Subject,
+				TokenType: "access_token",
+				ExpiresAt: claims.ExpiresAt.Unix(),
+				IssuedAt:  claims.IssuedAt.Unix(),
+			}
+		}
+	} else if refresh, err := cfg.Db.GetRefreshTokenByToken(r.Context(), token); err == nil {
+		if refresh.ClientID.UUID == client.ID && !refresh.RevokedAt.Valid && refresh.ExpiresAt.After(time.Now()) {
+			response = ResponseIntrospection{
+				Active:    true,
+				Scope:     refresh.Scope.String,
+				ClientID:  client.ID.String(),
+				Subject:   refresh.UserID.String(),
+				TokenType: "refresh_token",
+				ExpiresAt: refresh.ExpiresAt.Unix(),
+				IssuedAt:  refresh.CreatedAt.Unix(),
+			}
+		}
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleOAuthRevoke implements RFC 7009 for refresh tokens. Access tokens are
+// short-lived JWTs and simply expire.
+func (cfg *Api) handleOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	_, err = cfg.Db.RevokeClientRefreshToken(r.Context(), database.RevokeClientRefreshTokenParams{
+		Token:    r.PostForm.Get("token"),
+		ClientID: uuid.NullUUID{UUID: client.ID, Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error revoking client refresh token", "error", err)
+		writeOAuthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (cfg *Api) handleOAuthUserInfo(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWTWithScope(accessToken, cfg.JwtTokenSecret, auth.ScopeProfile)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	response := ResponseUserInfo{
+		Subject:     user.ID.String(),
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
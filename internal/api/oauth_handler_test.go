@@ -0,0 +1,369 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+const (
+	testRedirectURI  = "https://client.example.com/callback"
+	testCodeVerifier = "a-code-verifier-that-is-long-enough-for-pkce-0123456789"
+)
+
+// oauthDB serves one user, a set of public clients and the authorization
+// codes and refresh tokens issued to them.
+type oauthDB struct {
+	accountDB
+	clients       map[uuid.UUID]database.OauthClient
+	codes         map[string]database.OauthAuthorizationCode
+	refreshTokens map[string]database.RefreshToken
+}
+
+func (db *oauthDB) GetOAuthClientByID(ctx context.Context, id uuid.UUID) (database.OauthClient, error) {
+	client, ok := db.clients[id]
+	if !ok {
+		return database.OauthClient{}, sql.ErrNoRows
+	}
+	return client, nil
+}
+
+func (db *oauthDB) CreateOAuthAuthorizationCode(ctx context.Context, arg database.CreateOAuthAuthorizationCodeParams) error {
+	db.codes[arg.CodeHash] = database.OauthAuthorizationCode{
+		CodeHash:      arg.CodeHash,
+		ClientID:      arg.ClientID,
+		UserID:        arg.UserID,
+		RedirectUri:   arg.RedirectUri,
+		Scope:         arg.Scope,
+		CodeChallenge: arg.CodeChallenge,
+		ExpiresAt:     arg.ExpiresAt,
+	}
+	return nil
+}
+
+func (db *oauthDB) TakeOAuthAuthorizationCode(ctx context.Context, codeHash string) (database.OauthAuthorizationCode, error) {
+	code, ok := db.codes[codeHash]
+	if !ok {
+		return database.OauthAuthorizationCode{}, sql.ErrNoRows
+	}
+	delete(db.codes, codeHash)
+	return code, nil
+}
+
+func (db *oauthDB) CreateClientRefreshToken(ctx context.Context, arg database.CreateClientRefreshTokenParams) error {
+	db.refreshTokens[arg.Token] = database.RefreshToken{
+		Token:     arg.Token,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+		ClientID:  arg.ClientID,
+		Scope:     arg.Scope,
+	}
+	return nil
+}
+
+func (db *oauthDB) GetRefreshTokenByToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	refreshToken, ok := db.refreshTokens[token]
+	if !ok {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return refreshToken, nil
+}
+
+func (db *oauthDB) ClaimClientRefreshToken(ctx context.Context, arg database.ClaimClientRefreshTokenParams) (int64, error) {
+	refreshToken, ok := db.refreshTokens[arg.Token]
+	if !ok || refreshToken.ClientID != arg.ClientID || refreshToken.RevokedAt.Valid {
+		return 0, nil
+	}
+	refreshToken.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	db.refreshTokens[arg.Token] = refreshToken
+	return 1, nil
+}
+
+func newOAuthDB(t *testing.T) (*oauthDB, database.OauthClient) {
+	client := database.OauthClient{ID: uuid.New(), Name: "Client", RedirectUris: []string{testRedirectURI}}
+	db := &oauthDB{
+		accountDB:     *newAccountDB(t),
+		clients:       map[uuid.UUID]database.OauthClient{client.ID: client},
+		codes:         map[string]database.OauthAuthorizationCode{},
+		refreshTokens: map[string]database.RefreshToken{},
+	}
+	return db, client
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func postForm(target string, form url.Values) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	req.Header.Set("content-type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func consentForm(client database.OauthClient, scope, password, decision string) url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ID.String()},
+		"redirect_uri":          {testRedirectURI},
+		"scope":                 {scope},
+		"state":                 {"xyz"},
+		"code_challenge":        {pkceChallenge(testCodeVerifier)},
+		"code_challenge_method": {"S256"},
+		"email":                 {"walt@example.com"},
+		"password":              {password},
+		"decision":              {decision},
+	}
+}
+
+// authorize runs the consent form and returns the authorization code handed
+// to the client, if any.
+func authorize(t *testing.T, cfg *Api, form url.Values) (int, url.Values) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	cfg.handleOAuthConsent(rec, postForm("/oauth/authorize", form))
+	if rec.Code != http.StatusFound {
+		return rec.Code, nil
+	}
+
+	location, err := url.Parse(rec.Header().Get("location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+	return rec.Code, location.Query()
+}
+
+func TestHandleOAuthConsent(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		decision   string
+		scope      string
+		wantStatus int
+		wantError  string
+		wantCode   bool
+	}{
+		{name: "Allow", password: "04234", decision: "allow", scope: "profile", wantStatus: http.StatusFound, wantCode: true},
+		{name: "Deny", password: "04234", decision: "deny", scope: "profile", wantStatus: http.StatusFound, wantError: "access_denied"},
+		{name: "Wrong password", password: "wrong", decision: "allow", scope: "profile", wantStatus: http.StatusUnauthorized},
+		{name: "Unknown scope", password: "04234", decision: "allow", scope: "admin", wantStatus: http.StatusFound, wantError: "invalid_scope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, client := newOAuthDB(t)
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+			status, query := authorize(t, cfg, consentForm(client, tt.scope, tt.password, tt.decision))
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if got := query.Get("error"); got != tt.wantError {
+				t.Errorf("error = %q, want %q", got, tt.wantError)
+			}
+			if (query.Get("code") != "") != tt.wantCode || (len(db.codes) > 0) != tt.wantCode {
+				t.Errorf("code = %q, stored codes = %d, want code %v", query.Get("code"), len(db.codes), tt.wantCode)
+			}
+			if tt.wantCode && query.Get("state") != "xyz" {
+				t.Errorf("state = %q, want %q", query.Get("state"), "xyz")
+			}
+		})
+	}
+}
+
+func TestConsentPageCannotBeFramed(t *testing.T) {
+	db, client := newOAuthDB(t)
+	cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+	requests := map[string]func(*httptest.ResponseRecorder){
+		"Authorize": func(rec *httptest.ResponseRecorder) {
+			query := consentForm(client, "profile", "", "")
+			query.Set("response_type", "code")
+			cfg.handleOAuthAuthorize(rec, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil))
+		},
+		"Failed consent": func(rec *httptest.ResponseRecorder) {
+			cfg.handleOAuthConsent(rec, postForm("/oauth/authorize", consentForm(client, "profile", "wrong", "allow")))
+		},
+	}
+
+	for name, request := range requests {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			request(rec)
+
+			if !strings.HasPrefix(rec.Header().Get("content-type"), "text/html") {
+				t.Fatalf("status = %d, content-type = %q, want the consent page", rec.Code, rec.Header().Get("content-type"))
+			}
+			if got := rec.Header().Get("x-frame-options"); got != "DENY" {
+				t.Errorf("x-frame-options = %q, want %q", got, "DENY")
+			}
+			if got := rec.Header().Get("content-security-policy"); got != "frame-ancestors 'none'" {
+				t.Errorf("content-security-policy = %q, want %q", got, "frame-ancestors 'none'")
+			}
+		})
+	}
+}
+
+func TestHandleOAuthToken(t *testing.T) {
+	otherClient := database.OauthClient{ID: uuid.New(), Name: "Other", RedirectUris: []string{testRedirectURI}}
+
+	tests := []struct {
+		name        string
+		otherClient bool
+		redirectURI string
+		verifier    string
+		wantStatus  int
+	}{
+		{name: "Valid exchange", redirectURI: testRedirectURI, verifier: testCodeVerifier, wantStatus: http.StatusOK},
+		{name: "Wrong code verifier", redirectURI: testRedirectURI, verifier: "wrong", wantStatus: http.StatusBadRequest},
+		{name: "Wrong redirect URI", redirectURI: "https://evil.example.com/", verifier: testCodeVerifier, wantStatus: http.StatusBadRequest},
+		{
+			name:        "Code issued to another client",
+			otherClient: true,
+			redirectURI: testRedirectURI,
+			verifier:    testCodeVerifier,
+			wantStatus:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, client := newOAuthDB(t)
+			db.clients[otherClient.ID] = otherClient
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+			_, query := authorize(t, cfg, consentForm(client, "profile", "04234", "allow"))
+
+			clientID := client.ID
+			if tt.otherClient {
+				clientID = otherClient.ID
+			}
+			rec := httptest.NewRecorder()
+			cfg.handleOAuthToken(rec, postForm("/oauth/token", url.Values{
+				"grant_type":    {"authorization_code"},
+				"client_id":     {clientID.String()},
+				"code":          {query.Get("code")},
+				"redirect_uri":  {tt.redirectURI},
+				"code_verifier": {tt.verifier},
+			}))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var response ResponseOAuthToken
+			err := json.NewDecoder(rec.Body).Decode(&response)
+			if err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if response.Scope != "profile" {
+				t.Errorf("scope = %q, want %q", response.Scope, "profile")
+			}
+			if len(db.codes) != 0 {
+				t.Error("authorization code was not consumed")
+			}
+		})
+	}
+}
+
+func TestHandleOAuthTokenRotatesRefreshTokens(t *testing.T) {
+	db, client := newOAuthDB(t)
+	cfg := &Api{Db: db, InTx: inlineTx(db), JwtTokenSecret: testSecret}
+
+	token := func(form url.Values) (int, ResponseOAuthToken) {
+		form.Set("client_id", client.ID.String())
+		rec := httptest.NewRecorder()
+		cfg.handleOAuthToken(rec, postForm("/oauth/token", form))
+
+		var response ResponseOAuthToken
+		if rec.Code == http.StatusOK {
+			err := json.NewDecoder(rec.Body).Decode(&response)
+			if err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+		}
+		return rec.Code, response
+	}
+	refresh := func(refreshToken string) (int, ResponseOAuthToken) {
+		return token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+	}
+
+	_, query := authorize(t, cfg, consentForm(client, "profile", "04234", "allow"))
+	status, first := token(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {query.Get("code")},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {testCodeVerifier},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("code exchange status = %d, want %d", status, http.StatusOK)
+	}
+
+	status, second := refresh(first.RefreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("refresh status = %d, want %d", status, http.StatusOK)
+	}
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh token = %q, want a new one", second.RefreshToken)
+	}
+	if second.Scope != "profile" {
+		t.Errorf("scope = %q, want %q", second.Scope, "profile")
+	}
+
+	if status, _ := refresh(first.RefreshToken); status != http.StatusBadRequest {
+		t.Errorf("reused refresh token status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status, _ := refresh(second.RefreshToken); status != http.StatusOK {
+		t.Errorf("rotated refresh token status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestOAuthScopeEnforcement(t *testing.T) {
+	db, client := newOAuthDB(t)
+	cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+	tests := []struct {
+		name       string
+		scope      string
+		handler    http.HandlerFunc
+		wantStatus int
+	}{
+		{name: "Userinfo with profile scope", scope: auth.ScopeProfile, handler: cfg.handleOAuthUserInfo, wantStatus: http.StatusOK},
+		{name: "Userinfo without profile scope", scope: auth.ScopeChirpsWrite, handler: cfg.handleOAuthUserInfo, wantStatus: http.StatusUnauthorized},
+		{name: "First-party endpoint", scope: auth.ScopeProfile + " " + auth.ScopeChirpsWrite, handler: cfg.handleExportUser, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := auth.MakeScopedJWT(db.user.ID, testSecret, client.ID, tt.scope, auth.DefaultDuration)
+			if err != nil {
+				t.Fatalf("MakeScopedJWT() error = %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			tt.handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+	"github.com/joaogiacometti/goserver/internal/oidc"
+)
+
+const OidcLoginStateDuration = time.Minute * 10
+
+// oidcStateCookie binds a login to the browser that started it. Without it an
+// attacker could send a victim to the callback with the attacker's own code
+// and state and sign them in to the attacker's account.
+const oidcStateCookie = "oidc_state"
+
+func (cfg *Api) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.OIDCProviders[r.PathValue("provider")]
+	if !ok {
+		http.Error(w, "Unknown identity provider", http.StatusNotFound)
+		return
+	}
+
+	err := cfg.Db.DeleteExpiredOidcLoginStates(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error deleting expired login states", "error", err)
+	}
+
+	var values [3]string
+	for i := range values {
+		values[i], err = oidc.RandomString()
+		if err != nil {
+			http.Error(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
+	}
+	state, nonce, codeVerifier := values[0], values[1], values[2]
+
+	err = cfg.Db.CreateOidcLoginState(r.Context(), database.CreateOidcLoginStateParams{
+		State:        state,
+		Provider:     provider.Name,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(OidcLoginStateDuration),
+	})
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/login/oidc/" + provider.Name,
+		MaxAge:   int(OidcLoginStateDuration.Seconds()),
+		Secure:   cfg.Platform != "dev",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, provider.AuthCodeURL(state, nonce, codeVerifier), http.StatusFound)
+}
+
+func (cfg *Api) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.OIDCProviders[r.PathValue("provider")]
+	if !ok {
+		http.Error(w, "Unknown identity provider", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		http.Error(w, "Login was denied by the identity provider", http.StatusUnauthorized)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		http.Error(w, "Invalid or expired login state", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:   oidcStateCookie,
+		Path:   "/api/login/oidc/" + provider.Name,
+		MaxAge: -1,
+	})
+
+	loginState, err := cfg.Db.TakeOidcLoginState(r.Context(), query.Get("state"))
+	if err != nil || loginState.Provider != provider.Name {
+		http.Error(w, "Invalid or expired login state", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := provider.Exchange(r.Context(), query.Get("code"), loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error completing OIDC login", "error", err)
+		http.Error(w, "Failed to verify identity", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.resolveOIDCUser(r, provider.Name, identity)
+	if errors.Is(err, errUnverifiedEmail) {
+		http.Error(w, "An account with this email already exists; verify your email with the provider to link it", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, errUnverifiedSignup) {
+		http.Error(w, "Verify your email with the identity provider to sign up", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, errSignupsDisabled) {
+		http.Error(w, "Signups are disabled", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error resolving OIDC user", "error", err)
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+
+	if user.TotpEnabled {
+		cfg.respondWithMFAChallenge(w, r, user)
+		return
+	}
+
+	cfg.completeLogin(w, r, user)
+}
+
+var (
+	errUnverifiedEmail  = errors.New("identity email is not verified")
+	errUnverifiedSignup = errors.New("identity email is not verified, refusing to create an account")
+	errSignupsDisabled  = errors.New("signups are disabled")
+)
+
+// resolveOIDCUser finds the user behind an external identity. Unknown
+// identities are linked to an existing account, or used to create a new one,
+// only when the provider vouches for the email address.
+func (cfg *Api) resolveOIDCUser(r *http.Request, providerName string, identity oidc.Identity) (database.User, error) {
+	linked, err := cfg.Db.GetUserIdentity(r.Context(), database.GetUserIdentityParams{
+		Provider: providerName,
+		Subject:  identity.Subject,
+	})
+	if err == nil {
+		return cfg.Db.GetUserByID(r.Context(), linked.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	user, err := cfg.Db.GetUserByEmail(r.Context(), identity.Email)
+	switch {
+	case err == nil:
+		if !identity.EmailVerified {
+			return database.User{}, errUnverifiedEmail
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		if identity.Email == "" {
+			return database.User{}, errors.New("identity has no email")
+		}
+		if !identity.EmailVerified {
+			return database.User{}, errUnverifiedSignup
+		}
+		if cfg.DisableSignups {
+			return database.User{}, errSignupsDisabled
+		}
+
+		password, err := auth.MakeRefreshToken()
+		if err != nil {
+			return database.User{}, err
+		}
+
+		hashedPassword, err := auth.HashPassword(password)
+		if err != nil {
+			return database.User{}, err
+		}
+
+		user, err = cfg.Db.CreateUser(r.Context(), database.CreateUserParams{
+			Email:          identity.Email,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+	default:
+		return database.User{}, err
+	}
+
+	err = cfg.Db.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+		Provider: providerName,
+		Subject:  identity.Subject,
+		UserID:   user.ID,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+	"github.com/joaogiacometti/goserver/internal/oidc"
+)
+
+// oidcDB stores login states and accounts created through OIDC in memory.
+type oidcDB struct {
+	database.Querier
+	states  map[string]database.OidcLoginState
+	users   map[string]database.User
+	created int
+}
+
+func (db *oidcDB) DeleteExpiredOidcLoginStates(ctx context.Context) error {
+	return nil
+}
+
+func (db *oidcDB) CreateOidcLoginState(ctx context.Context, arg database.CreateOidcLoginStateParams) error {
+	db.states[arg.State] = database.OidcLoginState{State: arg.State, Provider: arg.Provider}
+	return nil
+}
+
+func (db *oidcDB) TakeOidcLoginState(ctx context.Context, state string) (database.OidcLoginState, error) {
+	loginState, ok := db.states[state]
+	if !ok {
+		return database.OidcLoginState{}, sql.ErrNoRows
+	}
+	delete(db.states, state)
+	return loginState, nil
+}
+
+func (db *oidcDB) GetUserIdentity(ctx context.Context, arg database.GetUserIdentityParams) (database.UserIdentity, error) {
+	return database.UserIdentity{}, sql.ErrNoRows
+}
+
+func (db *oidcDB) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	user, ok := db.users[email]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (db *oidcDB) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	db.created++
+	user := database.User{ID: uuid.New(), Email: arg.Email}
+	db.users[arg.Email] = user
+	return user, nil
+}
+
+func (db *oidcDB) CreateUserIdentity(ctx context.Context, arg database.CreateUserIdentityParams) error {
+	return nil
+}
+
+func newOIDCDB() *oidcDB {
+	return &oidcDB{states: map[string]database.OidcLoginState{}, users: map[string]database.User{}}
+}
+
+func TestOIDCStateCookie(t *testing.T) {
+	db := newOIDCDB()
+	cfg := &Api{Db: db, OIDCProviders: map[string]*oidc.Provider{"example": {Name: "example"}}}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/login/oidc/example", nil)
+	req.SetPathValue("provider", "example")
+	rec := httptest.NewRecorder()
+	cfg.handleOIDCLogin(rec, req)
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusFound)
+	}
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oidcStateCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("login did not set the state cookie")
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.MaxAge <= 0 {
+		t.Errorf("state cookie = %+v, want short-lived, HttpOnly and SameSite=Lax", cookie)
+	}
+	if _, ok := db.states[cookie.Value]; !ok {
+		t.Fatalf("state cookie %q does not match a stored login state", cookie.Value)
+	}
+
+	tests := []struct {
+		name       string
+		cookie     string
+		wantStatus int
+	}{
+		{name: "Missing cookie", wantStatus: http.StatusBadRequest},
+		{name: "Cookie from another login", cookie: "attacker-state", wantStatus: http.StatusBadRequest},
+		// A matching cookie gets past the state check and fails at the code exchange.
+		{name: "Matching cookie", cookie: cookie.Value, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/login/oidc/example/callback?code=abc&state="+cookie.Value, nil)
+			req.SetPathValue("provider", "example")
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tt.cookie})
+			}
+
+			rec := httptest.NewRecorder()
+			cfg.handleOIDCCallback(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestResolveOIDCUser(t *testing.T) {
+	tests := []struct {
+		name        string
+		identity    oidc.Identity
+		existing    bool
+		wantErr     error
+		wantCreated int
+	}{
+		{name: "Verified signup", identity: oidc.Identity{Subject: "1", Email: "new@example.com", EmailVerified: true}, wantCreated: 1},
+		{name: "Unverified signup", identity: oidc.Identity{Subject: "1", Email: "new@example.com"}, wantErr: errUnverifiedSignup},
+		{name: "Verified link", identity: oidc.Identity{Subject: "1", Email: "walt@example.com", EmailVerified: true}, existing: true},
+		{name: "Unverified link", identity: oidc.Identity{Subject: "1", Email: "walt@example.com"}, existing: true, wantErr: errUnverifiedEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newOIDCDB()
+			if tt.existing {
+				db.users["walt@example.com"] = database.User{ID: uuid.New(), Email: "walt@example.com"}
+			}
+			cfg := &Api{Db: db}
+
+			_, err := cfg.resolveOIDCUser(httptest.NewRequest(http.MethodGet, "/", nil), "example", tt.identity)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("resolveOIDCUser() error = %v, want %v", err, tt.wantErr)
+			}
+			if db.created != tt.wantCreated {
+				t.Errorf("created %d users, want %d", db.created, tt.wantCreated)
+			}
+		})
+	}
+}
@@ -0,0 +1,387 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+const WebauthnSessionDuration = time.Minute * 5
+
+type ResponsePasskeyCeremony struct {
+	SessionID string `json:"session_id"`
+	Options   any    `json:"options"`
+}
+
+type ResponsePasskey struct {
+	ID         string     `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// passkeyUser adapts a database user and its stored credentials to the
+// webauthn.User interface.
+type passkeyUser struct {
+	user        database.User
+	credentials []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	return u.user.Email
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func (cfg *Api) loadPasskeyUser(ctx context.Context, userID uuid.UUID) (*passkeyUser, error) {
+	user, err := cfg.Db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := cfg.Db.GetWebauthnCredentialsByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &passkeyUser{user: user}
+	for _, row := range rows {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(row.Credential, &credential); err != nil {
+			return nil, fmt.Errorf("invalid stored credential: %w", err)
+		}
+		result.credentials = append(result.credentials, credential)
+	}
+
+	return result, nil
+}
+
+func (cfg *Api) saveWebauthnSession(ctx context.Context, session *webauthn.SessionData) (uuid.UUID, error) {
+	err := cfg.Db.DeleteExpiredWebauthnSessions(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "error deleting expired webauthn sessions", "error", err)
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return cfg.Db.CreateWebauthnSession(ctx, database.CreateWebauthnSessionParams{
+		Data:      data,
+		ExpiresAt: time.Now().Add(WebauthnSessionDuration),
+	})
+}
+
+func (cfg *Api) takeWebauthnSession(ctx context.Context, r *http.Request) (webauthn.SessionData, error) {
+	var session webauthn.SessionData
+
+	sessionID, err := uuid.Parse(r.URL.Query().Get("session_id"))
+	if err != nil {
+		return session, errors.New("invalid session ID")
+	}
+
+	data, err := cfg.Db.TakeWebauthnSession(ctx, sessionID)
+	if err != nil {
+		return session, errors.New("unknown or expired session")
+	}
+
+	err = json.Unmarshal(data, &session)
+	return session, err
+}
+
+func (cfg *Api) handleBeginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	if cfg.WebAuthn == nil {
+		http.Error(w, "Passkeys are not configured", http.StatusNotImplemented)
+		return
+	}
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.loadPasskeyUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	creation, session, err := cfg.WebAuthn.BeginRegistration(
+		user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		http.Error(w, "Failed to begin registration", http.StatusInternalServerError)
+		return
+	}
+
+	sessionID, err := cfg.saveWebauthnSession(r.Context(), session)
+	if err != nil {
+		http.Error(w, "Failed to save session", http.StatusInternalServerError)
+		return
+	}
+
+	response := ResponsePasskeyCeremony{
+		SessionID: sessionID.String(),
+		Options:   creation,
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleFinishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	if cfg.WebAuthn == nil {
+		http.Error(w, "Passkeys are not configured", http.StatusNotImplemented)
+		return
+	}
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	session, err := cfg.takeWebauthnSession(r.Context(), r)
+	if err != nil {
+		http.Error(w, "Invalid or expired session", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.loadPasskeyUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	credential, err := cfg.WebAuthn.FinishRegistration(user, session, r)
+	if err != nil {
+		http.Error(w, "Failed to verify passkey", http.StatusBadRequest)
+		return
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		http.Error(w, "Failed to save passkey", http.StatusInternalServerError)
+		return
+	}
+
+	err = cfg.Db.CreateWebauthnCredential(r.Context(), database.CreateWebauthnCredentialParams{
+		ID:         credential.ID,
+		UserID:     user.user.ID,
+		Credential: data,
+	})
+	if err != nil {
+		http.Error(w, "Failed to save passkey", http.StatusInternalServerError)
+		return
+	}
+
+	response := ResponsePasskey{
+		ID:        base64.RawURLEncoding.EncodeToString(credential.ID),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleBeginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if cfg.WebAuthn == nil {
+		http.Error(w, "Passkeys are not configured", http.StatusNotImplemented)
+		return
+	}
+
+	assertion, session, err := cfg.WebAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		http.Error(w, "Failed to begin login", http.StatusInternalServerError)
+		return
+	}
+
+	sessionID, err := cfg.saveWebauthnSession(r.Context(), session)
+	if err != nil {
+		http.Error(w, "Failed to save session", http.StatusInternalServerError)
+		return
+	}
+
+	response := ResponsePasskeyCeremony{
+		SessionID: sessionID.String(),
+		Options:   assertion,
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleFinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if cfg.WebAuthn == nil {
+		http.Error(w, "Passkeys are not configured", http.StatusNotImplemented)
+		return
+	}
+
+	session, err := cfg.takeWebauthnSession(r.Context(), r)
+	if err != nil {
+		http.Error(w, "Invalid or expired session", http.StatusBadRequest)
+		return
+	}
+
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		return cfg.loadPasskeyUser(r.Context(), userID)
+	}
+
+	found, credential, err := cfg.WebAuthn.FinishPasskeyLogin(handler, session, r)
+	if err != nil {
+		http.Error(w, "Invalid passkey", http.StatusUnauthorized)
+		return
+	}
+
+	if credential.Authenticator.CloneWarning {
+		http.Error(w, "Passkey may have been cloned", http.StatusUnauthorized)
+		return
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		http.Error(w, "Failed to update passkey", http.StatusInternalServerError)
+		return
+	}
+
+	err = cfg.Db.UpdateWebauthnCredential(r.Context(), database.UpdateWebauthnCredentialParams{
+		Credential: data,
+		ID:         credential.ID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to update passkey", http.StatusInternalServerError)
+		return
+	}
+
+	cfg.completeLogin(w, r, found.(*passkeyUser).user)
+}
+
+func (cfg *Api) handleGetPasskeys(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	credentials, err := cfg.Db.GetWebauthnCredentialsByUserID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve passkeys", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponsePasskey{}
+	for _, credential := range credentials {
+		passkey := ResponsePasskey{
+			ID:        base64.RawURLEncoding.EncodeToString(credential.ID),
+			CreatedAt: credential.CreatedAt,
+		}
+		if credential.LastUsedAt.Valid {
+			passkey.LastUsedAt = &credential.LastUsedAt.Time
+		}
+		response = append(response, passkey)
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleDeletePasskey(w http.ResponseWriter, r *http.Request) {
+	credentialID, err := base64.RawURLEncoding.DecodeString(r.PathValue("passkeyID"))
+	if err != nil {
+		http.Error(w, "Invalid passkey ID", http.StatusBadRequest)
+		return
+	}
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deleted, err := cfg.Db.DeleteWebauthnCredential(r.Context(), database.DeleteWebauthnCredentialParams{
+		ID:     credentialID,
+		UserID: userID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete passkey", http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		http.Error(w, "Passkey not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
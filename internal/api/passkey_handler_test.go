@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+type storedWebauthnSession struct {
+	data      json.RawMessage
+	expiresAt time.Time
+}
+
+// passkeyDB keeps webauthn ceremony sessions in memory and, like the real
+// query, only hands out sessions that have not expired.
+type passkeyDB struct {
+	database.Querier
+	sessions map[uuid.UUID]storedWebauthnSession
+}
+
+func (db *passkeyDB) CreateWebauthnSession(ctx context.Context, arg database.CreateWebauthnSessionParams) (uuid.UUID, error) {
+	id := uuid.New()
+	db.sessions[id] = storedWebauthnSession{data: arg.Data, expiresAt: arg.ExpiresAt}
+	return id, nil
+}
+
+func (db *passkeyDB) TakeWebauthnSession(ctx context.Context, id uuid.UUID) (json.RawMessage, error) {
+	session, ok := db.sessions[id]
+	delete(db.sessions, id)
+	if !ok || !session.expiresAt.After(time.Now()) {
+		return nil, sql.ErrNoRows
+	}
+	return session.data, nil
+}
+
+func (db *passkeyDB) DeleteExpiredWebauthnSessions(ctx context.Context) error {
+	return nil
+}
+
+func TestHandleFinishPasskeyLoginSession(t *testing.T) {
+	passkeys, err := webauthn.New(&webauthn.Config{
+		RPID:          "localhost",
+		RPDisplayName: "Chirpy",
+		RPOrigins:     []string{"http://localhost"},
+	})
+	if err != nil {
+		t.Fatalf("webauthn.New() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		expiresIn  time.Duration
+		unknown    bool
+		wantStatus int
+	}{
+		// A live session gets past the lookup and fails on the empty assertion.
+		{name: "Live session", expiresIn: WebauthnSessionDuration, wantStatus: http.StatusUnauthorized},
+		{name: "Expired session", expiresIn: -time.Second, wantStatus: http.StatusBadRequest},
+		{name: "Unknown session", unknown: true, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &passkeyDB{sessions: map[uuid.UUID]storedWebauthnSession{}}
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret, WebAuthn: passkeys}
+
+			sessionID, err := cfg.saveWebauthnSession(context.Background(), &webauthn.SessionData{Challenge: "challenge"})
+			if err != nil {
+				t.Fatalf("saveWebauthnSession() error = %v", err)
+			}
+			session := db.sessions[sessionID]
+			session.expiresAt = time.Now().Add(tt.expiresIn)
+			db.sessions[sessionID] = session
+			if tt.unknown {
+				sessionID = uuid.New()
+			}
+
+			rec := httptest.NewRecorder()
+			cfg.handleFinishPasskeyLogin(rec, newRequest(t, http.MethodPost, "/api/login/passkey/finish?session_id="+sessionID.String(), "{}", uuid.Nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWebauthnSessionIsSingleUse(t *testing.T) {
+	db := &passkeyDB{sessions: map[uuid.UUID]storedWebauthnSession{}}
+	cfg := &Api{Db: db}
+
+	sessionID, err := cfg.saveWebauthnSession(context.Background(), &webauthn.SessionData{Challenge: "challenge"})
+	if err != nil {
+		t.Fatalf("saveWebauthnSession() error = %v", err)
+	}
+	if got := db.sessions[sessionID].expiresAt; got.After(time.Now().Add(WebauthnSessionDuration)) {
+		t.Errorf("session expires at %v, later than %v from now", got, WebauthnSessionDuration)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/?session_id="+sessionID.String(), nil)
+	session, err := cfg.takeWebauthnSession(context.Background(), req)
+	if err != nil || session.Challenge != "challenge" {
+		t.Fatalf("takeWebauthnSession() = %v, %v", session.Challenge, err)
+	}
+	if _, err := cfg.takeWebauthnSession(context.Background(), req); err == nil {
+		t.Error("takeWebauthnSession() returned the same session twice")
+	}
+}
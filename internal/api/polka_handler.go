@@ -1,55 +1,295 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
 )
 
 type RequestPolkaWebook struct {
+	ID    string `json:"id"`
 	Event string `json:"event"`
 	Data  struct {
-		UserId string `json:"user_id"`
+		UserId      string     `json:"user_id"`
+		PeriodStart *time.Time `json:"period_start"`
+		PeriodEnd   *time.Time `json:"period_end"`
 	} `json:"data"`
 }
 
 const (
-	UserUpgraded = "user.upgraded"
+	UserUpgraded      = "user.upgraded"
+	UserRenewed       = "user.renewed"
+	UserDowngraded    = "user.downgraded"
+	UserPaymentFailed = "user.payment_failed"
+	UserRefunded      = "user.refunded"
 )
 
+var polkaEvents = map[string]bool{
+	UserUpgraded:      true,
+	UserRenewed:       true,
+	UserDowngraded:    true,
+	UserPaymentFailed: true,
+	UserRefunded:      true,
+}
+
+const (
+	RedStatusNone     = "none"
+	RedStatusActive   = "active"
+	RedStatusPastDue  = "past_due"
+	RedStatusCanceled = "canceled"
+	RedStatusRefunded = "refunded"
+	RedStatusExpired  = "expired"
+)
+
+// RedPeriod is the billing period assumed when Polka does not send one, and
+// RedGracePeriod how long Red survives a failed payment past the period end.
+var (
+	RedPeriod      = time.Hour * 24 * 30
+	RedGracePeriod = time.Hour * 24 * 7
+)
+
+type ResponseRedSubscription struct {
+	Status      string     `json:"status"`
+	PeriodStart *time.Time `json:"period_start"`
+	PeriodEnd   *time.Time `json:"period_end"`
+	GraceUntil  *time.Time `json:"grace_until"`
+}
+
+type ResponseUserUpgraded struct {
+	UserID          string                  `json:"user_id"`
+	RedSubscription ResponseRedSubscription `json:"red_subscription"`
+}
+
+func mapRedSubscription(user database.User) ResponseRedSubscription {
+	response := ResponseRedSubscription{Status: user.RedStatus}
+	if user.RedPeriodStart.Valid {
+		response.PeriodStart = &user.RedPeriodStart.Time
+	}
+	if user.RedPeriodEnd.Valid {
+		response.PeriodEnd = &user.RedPeriodEnd.Time
+	}
+	if user.RedGraceUntil.Valid {
+		response.GraceUntil = &user.RedGraceUntil.Time
+	}
+	return response
+}
+
+const (
+	PolkaSignatureHeader = "X-Polka-Signature"
+	PolkaTimestampHeader = "X-Polka-Timestamp"
+)
+
+const MaxWebhookBodySize = 1 << 20
+
+// handlePolkaWebhooks accepts deliveries signed with an HMAC of the timestamp
+// and raw body and stores them in the webhook inbox, answering as soon as the
+// event is persisted. ProcessWebhookInbox applies it afterwards; a replayed
+// delivery is acknowledged without being stored again.
 func (cfg *Api) handlePolkaWebhooks(w http.ResponseWriter, r *http.Request) {
-	apiKey, err := auth.GetApiKey(r.Header)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxWebhookBodySize))
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	if apiKey != cfg.PolkaKey {
+
+	err = auth.VerifyWebhook(
+		cfg.PolkaKey,
+		r.Header.Get(PolkaSignatureHeader),
+		r.Header.Get(PolkaTimestampHeader),
+		body,
+		time.Now(),
+	)
+	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	var request RequestPolkaWebook
 
-	err = json.NewDecoder(r.Body).Decode(&request)
-	if err != nil {
+	err = json.Unmarshal(body, &request)
+	if err != nil || request.ID == "" || request.Event == "" {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	userID := uuid.MustParse(request.Data.UserId)
-
-	if request.Event != UserUpgraded {
+	if !polkaEvents[request.Event] {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	err = cfg.Db.UpgradeUserToRed(r.Context(), userID)
+	_, err = uuid.Parse(request.Data.UserId)
 	if err != nil {
-		http.Error(w, "Failed to upgrade user", http.StatusNotFound)
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
 
+	_, _, err = request.period(time.Now())
+	if err != nil {
+		http.Error(w, "Invalid subscription period", http.StatusBadRequest)
+		return
+	}
+
+	stored, err := cfg.Db.EnqueueWebhookEvent(r.Context(), database.EnqueueWebhookEventParams{
+		Provider: WebhookProviderPolka,
+		EventID:  request.ID,
+		Event:    request.Event,
+		Payload:  body,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error storing webhook event", "error", err)
+		http.Error(w, "Failed to record event", http.StatusInternalServerError)
+		return
+	}
+	if stored > 0 {
+		cfg.Metrics.WebhookReceived(WebhookProviderPolka, request.Event)
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
+
+var errWebhookUserNotFound = errors.New("webhook user not found")
+
+// period returns the billing period an event covers, in UTC. Periods Polka
+// leaves out start when the event was received and last RedPeriod.
+func (request RequestPolkaWebook) period(receivedAt time.Time) (time.Time, time.Time, error) {
+	start := receivedAt
+	if request.Data.PeriodStart != nil {
+		start = *request.Data.PeriodStart
+	}
+	end := start.Add(RedPeriod)
+	if request.Data.PeriodEnd != nil {
+		end = *request.Data.PeriodEnd
+	}
+
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: period ends before it starts", errWebhookPayload)
+	}
+	return start.UTC(), end.UTC(), nil
+}
+
+// stalePolkaEvent reports whether an event belongs to an earlier billing
+// period than the one stored, as when Polka delivers out of order or a retry
+// is overtaken by a renewal. Events with a period are compared by its end;
+// others by when they were received.
+func stalePolkaEvent(user database.User, request RequestPolkaWebook, receivedAt time.Time) bool {
+	if request.Data.PeriodEnd != nil {
+		return user.RedPeriodEnd.Valid && request.Data.PeriodEnd.Before(user.RedPeriodEnd.Time)
+	}
+	return user.RedPeriodStart.Valid && receivedAt.Before(user.RedPeriodStart.Time)
+}
+
+// applyPolkaEvent moves a user's Red subscription through its lifecycle.
+// Cancellations keep Red until the paid period ends and failed payments
+// until the grace period does; ExpireRedSubscriptions then removes it.
+// Stale events are ignored.
+func (cfg *Api) applyPolkaEvent(ctx context.Context, request RequestPolkaWebook, userID uuid.UUID, receivedAt time.Time) error {
+	start, end, err := request.period(receivedAt)
+	if err != nil {
+		return err
+	}
+
+	user, err := cfg.Db.GetUserByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errWebhookUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if stalePolkaEvent(user, request, receivedAt) {
+		slog.InfoContext(ctx, "ignoring stale Polka event", "event_id", request.ID, "event", request.Event)
+		return nil
+	}
+
+	switch request.Event {
+	case UserUpgraded, UserRenewed:
+		_, err = cfg.Db.ActivateRedSubscription(ctx, database.ActivateRedSubscriptionParams{
+			RedPeriodStart: sql.NullTime{Time: start, Valid: true},
+			RedPeriodEnd:   sql.NullTime{Time: end, Valid: true},
+			ID:             user.ID,
+		})
+		if err == nil && request.Event == UserUpgraded {
+			cfg.queueWebhookEvent(ctx, UserUpgraded, user.ID, ResponseUserUpgraded{
+				UserID: user.ID.String(),
+				RedSubscription: ResponseRedSubscription{
+					Status:      RedStatusActive,
+					PeriodStart: &start,
+					PeriodEnd:   &end,
+				},
+			})
+		}
+	case UserPaymentFailed:
+		if user.RedStatus != RedStatusActive && user.RedStatus != RedStatusPastDue {
+			return nil
+		}
+
+		graceFrom := time.Now().UTC()
+		if user.RedPeriodEnd.Valid {
+			graceFrom = user.RedPeriodEnd.Time
+		}
+
+		_, err = cfg.Db.SetRedSubscriptionStatus(ctx, database.SetRedSubscriptionStatusParams{
+			RedStatus:     RedStatusPastDue,
+			RedGraceUntil: sql.NullTime{Time: graceFrom.Add(RedGracePeriod), Valid: true},
+			ID:            user.ID,
+		})
+	case UserDowngraded:
+		if !user.IsChirpyRed {
+			return nil
+		}
+
+		// Memberships from before periods were tracked have nothing to run
+		// out, so they end immediately.
+		if !user.RedPeriodEnd.Valid {
+			_, err = cfg.Db.EndRedSubscription(ctx, database.EndRedSubscriptionParams{
+				RedStatus: RedStatusCanceled,
+				ID:        user.ID,
+			})
+			break
+		}
+
+		_, err = cfg.Db.SetRedSubscriptionStatus(ctx, database.SetRedSubscriptionStatusParams{
+			RedStatus: RedStatusCanceled,
+			ID:        user.ID,
+		})
+	case UserRefunded:
+		_, err = cfg.Db.EndRedSubscription(ctx, database.EndRedSubscriptionParams{
+			RedStatus: RedStatusRefunded,
+			ID:        user.ID,
+		})
+	}
+
+	return err
+}
+
+// ExpireRedSubscriptions removes Red from users whose paid period, or grace
+// period after a failed payment, has run out.
+func (cfg *Api) ExpireRedSubscriptions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := cfg.Db.ExpireRedSubscriptions(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "error expiring Red subscriptions", "error", err)
+		} else if expired > 0 {
+			slog.InfoContext(ctx, "expired Red subscriptions", "count", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
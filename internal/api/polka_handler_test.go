@@ -0,0 +1,266 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+// polkaDB applies the Red subscription queries to a single user.
+type polkaDB struct {
+	database.Querier
+	user    database.User
+	userErr error
+}
+
+func (db *polkaDB) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	if db.userErr != nil {
+		return database.User{}, db.userErr
+	}
+	if id != db.user.ID {
+		return database.User{}, sql.ErrNoRows
+	}
+	return db.user, nil
+}
+
+func (db *polkaDB) ActivateRedSubscription(ctx context.Context, arg database.ActivateRedSubscriptionParams) (int64, error) {
+	db.user.IsChirpyRed = true
+	db.user.RedStatus = RedStatusActive
+	db.user.RedPeriodStart = arg.RedPeriodStart
+	db.user.RedPeriodEnd = arg.RedPeriodEnd
+	db.user.RedGraceUntil = sql.NullTime{}
+	return 1, nil
+}
+
+func (db *polkaDB) SetRedSubscriptionStatus(ctx context.Context, arg database.SetRedSubscriptionStatusParams) (int64, error) {
+	db.user.RedStatus = arg.RedStatus
+	db.user.RedGraceUntil = arg.RedGraceUntil
+	return 1, nil
+}
+
+func (db *polkaDB) EndRedSubscription(ctx context.Context, arg database.EndRedSubscriptionParams) (int64, error) {
+	db.user.IsChirpyRed = false
+	db.user.RedStatus = arg.RedStatus
+	db.user.RedGraceUntil = sql.NullTime{}
+	return 1, nil
+}
+
+func polkaEvent(event string, start, end *time.Time) RequestPolkaWebook {
+	var request RequestPolkaWebook
+	request.ID = uuid.NewString()
+	request.Event = event
+	request.Data.PeriodStart = start
+	request.Data.PeriodEnd = end
+	return request
+}
+
+func TestApplyPolkaEvent(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	periodStart := now.Add(-time.Hour * 24)
+	periodEnd := periodStart.Add(RedPeriod)
+	nextEnd := periodEnd.Add(RedPeriod)
+	earlierEnd := periodStart.Add(-time.Hour)
+	earlierStart := earlierEnd.Add(-RedPeriod)
+	localStart := periodStart.In(time.FixedZone("BRT", -3*60*60))
+	localEnd := periodEnd.In(time.FixedZone("BRT", -3*60*60))
+
+	active := database.User{
+		IsChirpyRed:    true,
+		RedStatus:      RedStatusActive,
+		RedPeriodStart: sql.NullTime{Time: periodStart, Valid: true},
+		RedPeriodEnd:   sql.NullTime{Time: periodEnd, Valid: true},
+	}
+	legacy := database.User{IsChirpyRed: true, RedStatus: RedStatusActive}
+	none := database.User{RedStatus: RedStatusNone}
+
+	tests := []struct {
+		name       string
+		user       database.User
+		request    RequestPolkaWebook
+		receivedAt time.Time
+		wantErr    error
+		wantRed    bool
+		wantStatus string
+		wantEnd    time.Time
+		wantGrace  time.Time
+	}{
+		{
+			name:       "Upgrade without a period",
+			user:       none,
+			request:    polkaEvent(UserUpgraded, nil, nil),
+			receivedAt: now,
+			wantRed:    true,
+			wantStatus: RedStatusActive,
+			wantEnd:    now.Add(RedPeriod),
+		},
+		{
+			name:       "Upgrade stores the period in UTC",
+			user:       none,
+			request:    polkaEvent(UserUpgraded, &localStart, &localEnd),
+			receivedAt: now,
+			wantRed:    true,
+			wantStatus: RedStatusActive,
+			wantEnd:    periodEnd,
+		},
+		{
+			name:       "Renewal extends the period",
+			user:       active,
+			request:    polkaEvent(UserRenewed, &periodEnd, &nextEnd),
+			receivedAt: now,
+			wantRed:    true,
+			wantStatus: RedStatusActive,
+			wantEnd:    nextEnd,
+		},
+		{
+			name:       "Renewal for an earlier period is ignored",
+			user:       active,
+			request:    polkaEvent(UserRenewed, &earlierStart, &earlierEnd),
+			receivedAt: now,
+			wantRed:    true,
+			wantStatus: RedStatusActive,
+			wantEnd:    periodEnd,
+		},
+		{
+			name:       "Period ending before it starts",
+			user:       active,
+			request:    polkaEvent(UserRenewed, &nextEnd, &periodEnd),
+			receivedAt: now,
+			wantErr:    errWebhookPayload,
+			wantRed:    true,
+			wantStatus: RedStatusActive,
+			wantEnd:    periodEnd,
+		},
+		{
+			name:       "Empty period",
+			user:       active,
+			request:    polkaEvent(UserRenewed, &nextEnd, &nextEnd),
+			receivedAt: now,
+			wantErr:    errWebhookPayload,
+			wantRed:    true,
+			wantStatus: RedStatusActive,
+			wantEnd:    periodEnd,
+		},
+		{
+			name:       "Payment failure starts the grace period",
+			user:       active,
+			request:    polkaEvent(UserPaymentFailed, nil, nil),
+			receivedAt: now,
+			wantRed:    true,
+			wantStatus: RedStatusPastDue,
+			wantEnd:    periodEnd,
+			wantGrace:  periodEnd.Add(RedGracePeriod),
+		},
+		{
+			name:       "Payment failure received before the current period is ignored",
+			user:       active,
+			request:    polkaEvent(UserPaymentFailed, nil, nil),
+			receivedAt: periodStart.Add(-time.Minute),
+			wantRed:    true,
+			wantStatus: RedStatusActive,
+			wantEnd:    periodEnd,
+		},
+		{
+			name:       "Payment failure without a subscription",
+			user:       none,
+			request:    polkaEvent(UserPaymentFailed, nil, nil),
+			receivedAt: now,
+			wantRed:    false,
+			wantStatus: RedStatusNone,
+		},
+		{
+			name:       "Downgrade keeps Red until the period ends",
+			user:       active,
+			request:    polkaEvent(UserDowngraded, nil, nil),
+			receivedAt: now,
+			wantRed:    true,
+			wantStatus: RedStatusCanceled,
+			wantEnd:    periodEnd,
+		},
+		{
+			name:       "Downgrade for an earlier period is ignored",
+			user:       active,
+			request:    polkaEvent(UserDowngraded, &earlierStart, &earlierEnd),
+			receivedAt: now,
+			wantRed:    true,
+			wantStatus: RedStatusActive,
+			wantEnd:    periodEnd,
+		},
+		{
+			name:       "Downgrade of an untracked membership ends it",
+			user:       legacy,
+			request:    polkaEvent(UserDowngraded, nil, nil),
+			receivedAt: now,
+			wantRed:    false,
+			wantStatus: RedStatusCanceled,
+		},
+		{
+			name:       "Refund ends Red",
+			user:       active,
+			request:    polkaEvent(UserRefunded, nil, nil),
+			receivedAt: now,
+			wantRed:    false,
+			wantStatus: RedStatusRefunded,
+			wantEnd:    periodEnd,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := tt.user
+			user.ID = uuid.New()
+			db := &polkaDB{user: user}
+			cfg := &Api{Db: db, DisableOutgoingWebhooks: true}
+
+			err := cfg.applyPolkaEvent(context.Background(), tt.request, user.ID, tt.receivedAt)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("applyPolkaEvent() error = %v, want %v", err, tt.wantErr)
+			}
+
+			got := db.user
+			if got.IsChirpyRed != tt.wantRed || got.RedStatus != tt.wantStatus {
+				t.Errorf("red = %v, status = %s, want %v, %s", got.IsChirpyRed, got.RedStatus, tt.wantRed, tt.wantStatus)
+			}
+			if !got.RedPeriodEnd.Time.Equal(tt.wantEnd) {
+				t.Errorf("period end = %v, want %v", got.RedPeriodEnd.Time, tt.wantEnd)
+			}
+			if got.RedPeriodEnd.Valid && got.RedPeriodEnd.Time.Location() != time.UTC {
+				t.Errorf("period end stored in %v, want UTC", got.RedPeriodEnd.Time.Location())
+			}
+			if !got.RedGraceUntil.Time.Equal(tt.wantGrace) {
+				t.Errorf("grace until = %v, want %v", got.RedGraceUntil.Time, tt.wantGrace)
+			}
+		})
+	}
+}
+
+func TestApplyPolkaEventUserLookup(t *testing.T) {
+	tests := []struct {
+		name         string
+		userErr      error
+		wantNotFound bool
+	}{
+		{name: "Unknown user", userErr: sql.ErrNoRows, wantNotFound: true},
+		{name: "Database unavailable", userErr: errors.New("connection refused"), wantNotFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &polkaDB{userErr: tt.userErr}
+			cfg := &Api{Db: db}
+
+			err := cfg.applyPolkaEvent(context.Background(), polkaEvent(UserUpgraded, nil, nil), uuid.New(), time.Now())
+
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.Is(err, errWebhookUserNotFound) != tt.wantNotFound {
+				t.Errorf("error = %v, want not found %v", err, tt.wantNotFound)
+			}
+		})
+	}
+}
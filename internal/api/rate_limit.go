@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/entitlements"
+)
+
+// RateLimitWindow is the fixed window RequestsPerMinute is counted over.
+var RateLimitWindow = time.Minute
+
+// rateLimiter counts each user's requests in the current window. The limit
+// is resolved once per window, so plan changes apply from the next one.
+// Counts live in memory, so every instance enforces its own limit.
+type rateLimiter struct {
+	mu        sync.Mutex
+	windows   map[uuid.UUID]*rateWindow
+	lastSweep time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	limit int
+	count int
+}
+
+// allow records a request by userID at now. When the user is over their
+// limit it returns false and how long until the window resets.
+func (l *rateLimiter) allow(ctx context.Context, resolver entitlements.Resolver, userID uuid.UUID, now time.Time) (bool, time.Duration, error) {
+	l.mu.Lock()
+	window, ok := l.windows[userID]
+	if ok && now.Sub(window.start) < RateLimitWindow {
+		window.count++
+		allowed := window.limit <= 0 || window.count <= window.limit
+		retryAfter := window.start.Add(RateLimitWindow).Sub(now)
+		l.mu.Unlock()
+		return allowed, retryAfter, nil
+	}
+	l.mu.Unlock()
+
+	capabilities, err := resolver.Resolve(ctx, userID)
+	if err != nil {
+		return true, 0, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.windows == nil {
+		l.windows = map[uuid.UUID]*rateWindow{}
+	}
+	if now.Sub(l.lastSweep) >= RateLimitWindow {
+		for id, window := range l.windows {
+			if now.Sub(window.start) >= RateLimitWindow {
+				delete(l.windows, id)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	window, ok = l.windows[userID]
+	if !ok || now.Sub(window.start) >= RateLimitWindow {
+		window = &rateWindow{start: now, limit: capabilities.RequestsPerMinute}
+		l.windows[userID] = window
+	}
+	window.count++
+	return window.limit <= 0 || window.count <= window.limit, window.start.Add(RateLimitWindow).Sub(now), nil
+}
+
+// middlewareRateLimit holds each signed-in user to their plan's
+// RequestsPerMinute. Requests without a valid access token pass through to
+// be rejected, or served anonymously, by the handler. If the limit cannot be
+// resolved the request is let through rather than failing the API.
+func (cfg *Api) middlewareRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.Entitlements == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := auth.ParseAccessToken(token, cfg.JwtTokenSecret)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, err := claims.UserID()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, retryAfter, err := cfg.rateLimits.allow(r.Context(), cfg.Entitlements, userID, time.Now())
+		if err != nil {
+			slog.WarnContext(r.Context(), "error resolving rate limit", "error", err)
+		}
+		if !allowed {
+			w.Header().Set("retry-after", strconv.Itoa(int(retryAfter.Seconds())+1))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/entitlements"
+)
+
+type resolverFunc func(ctx context.Context, userID uuid.UUID) (entitlements.Capabilities, error)
+
+func (f resolverFunc) Resolve(ctx context.Context, userID uuid.UUID) (entitlements.Capabilities, error) {
+	return f(ctx, userID)
+}
+
+func fixedLimit(limit int) resolverFunc {
+	return func(ctx context.Context, userID uuid.UUID) (entitlements.Capabilities, error) {
+		return entitlements.Capabilities{RequestsPerMinute: limit}, nil
+	}
+}
+
+func TestMiddlewareRateLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolver entitlements.Resolver
+		signedIn bool
+		requests int
+		wantLast int
+	}{
+		{name: "Under limit", resolver: fixedLimit(3), signedIn: true, requests: 3, wantLast: http.StatusOK},
+		{name: "Over limit", resolver: fixedLimit(3), signedIn: true, requests: 4, wantLast: http.StatusTooManyRequests},
+		{name: "Unlimited plan", resolver: fixedLimit(0), signedIn: true, requests: 10, wantLast: http.StatusOK},
+		{name: "Anonymous", resolver: fixedLimit(1), requests: 3, wantLast: http.StatusOK},
+		{
+			name: "Resolver failure",
+			resolver: resolverFunc(func(ctx context.Context, userID uuid.UUID) (entitlements.Capabilities, error) {
+				return entitlements.Capabilities{}, errors.New("connection refused")
+			}),
+			signedIn: true,
+			requests: 3,
+			wantLast: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Api{JwtTokenSecret: testSecret, Entitlements: tt.resolver}
+			handler := cfg.middlewareRateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			userID := uuid.Nil
+			if tt.signedIn {
+				userID = uuid.New()
+			}
+
+			var rec *httptest.ResponseRecorder
+			for range tt.requests {
+				rec = httptest.NewRecorder()
+				handler.ServeHTTP(rec, newRequest(t, http.MethodGet, "/api/chirps", "", userID))
+			}
+
+			if rec.Code != tt.wantLast {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantLast)
+			}
+			if tt.wantLast == http.StatusTooManyRequests && rec.Header().Get("retry-after") == "" {
+				t.Error("expected a retry-after header")
+			}
+		})
+	}
+}
+
+func TestRateLimiterWindows(t *testing.T) {
+	var limiter rateLimiter
+	resolves := 0
+	resolver := resolverFunc(func(ctx context.Context, userID uuid.UUID) (entitlements.Capabilities, error) {
+		resolves++
+		return entitlements.Capabilities{RequestsPerMinute: 2}, nil
+	})
+
+	userID, otherID := uuid.New(), uuid.New()
+	start := time.Now()
+
+	steps := []struct {
+		userID uuid.UUID
+		at     time.Time
+		want   bool
+	}{
+		{userID: userID, at: start, want: true},
+		{userID: userID, at: start.Add(time.Second), want: true},
+		{userID: userID, at: start.Add(time.Second * 2), want: false},
+		{userID: otherID, at: start.Add(time.Second * 3), want: true},
+		{userID: userID, at: start.Add(RateLimitWindow), want: true},
+	}
+
+	for i, step := range steps {
+		allowed, _, err := limiter.allow(context.Background(), resolver, step.userID, step.at)
+		if err != nil {
+			t.Fatalf("step %d: allow() error = %v", i, err)
+		}
+		if allowed != step.want {
+			t.Errorf("step %d: allowed = %v, want %v", i, allowed, step.want)
+		}
+	}
+
+	if resolves != 3 {
+		t.Errorf("resolved limits %d times, want once per user and window", resolves)
+	}
+}
@@ -0,0 +1,330 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+	"github.com/lib/pq"
+)
+
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusDismissed = "dismissed"
+	ReportStatusActioned  = "actioned"
+)
+
+const (
+	ReportActionDismiss = "dismiss"
+	ReportActionHide    = "hide"
+	ReportActionDelete  = "delete"
+	ReportActionSuspend = "suspend"
+)
+
+const MaxReportReasonLength = 500
+
+type RequestReportChirp struct {
+	Reason string `json:"reason"`
+}
+
+type RequestResolveReport struct {
+	Action       string     `json:"action"`
+	Note         string     `json:"note"`
+	SuspendUntil *time.Time `json:"suspend_until"`
+}
+
+type ResponseReport struct {
+	ID             string     `json:"id"`
+	ChirpID        *string    `json:"chirp_id"`
+	ChirpAuthorID  string     `json:"chirp_author_id"`
+	ChirpBody      string     `json:"chirp_body"`
+	ReporterID     string     `json:"reporter_id"`
+	Reason         string     `json:"reason"`
+	Status         string     `json:"status"`
+	Resolution     string     `json:"resolution,omitempty"`
+	ResolutionNote string     `json:"resolution_note,omitempty"`
+	ResolvedBy     *string    `json:"resolved_by"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func mapReportToResponse(report database.ChirpReport) ResponseReport {
+	response := ResponseReport{
+		ID:             report.ID.String(),
+		ChirpAuthorID:  report.ChirpAuthorID.String(),
+		ChirpBody:      report.ChirpBody,
+		ReporterID:     report.ReporterID.String(),
+		Reason:         report.Reason,
+		Status:         report.Status,
+		Resolution:     report.Resolution.String,
+		ResolutionNote: report.ResolutionNote.String,
+		CreatedAt:      report.CreatedAt,
+	}
+	if report.ChirpID.Valid {
+		chirpID := report.ChirpID.UUID.String()
+		response.ChirpID = &chirpID
+	}
+	if report.ResolvedBy.Valid {
+		resolvedBy := report.ResolvedBy.UUID.String()
+		response.ResolvedBy = &resolvedBy
+	}
+	if report.ResolvedAt.Valid {
+		response.ResolvedAt = &report.ResolvedAt.Time
+	}
+	return response
+}
+
+func (cfg *Api) handleReportChirp(w http.ResponseWriter, r *http.Request) {
+	var request RequestReportChirp
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		http.Error(w, "Invalid chirp ID", http.StatusBadRequest)
+		return
+	}
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.Reason == "" || len(request.Reason) > MaxReportReasonLength {
+		http.Error(w, fmt.Sprintf("Reason must be between 1 and %d characters", MaxReportReasonLength), http.StatusBadRequest)
+		return
+	}
+
+	chirp, err := cfg.Db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve chirp", http.StatusNotFound)
+		return
+	}
+
+	visible, err := cfg.canViewChirp(r.Context(), chirp, userID)
+	if err != nil || !visible {
+		http.Error(w, "Failed to retrieve chirp", http.StatusNotFound)
+		return
+	}
+
+	if chirp.UserID == userID {
+		http.Error(w, "You cannot report your own chirp", http.StatusBadRequest)
+		return
+	}
+
+	report, err := cfg.Db.CreateChirpReport(r.Context(), database.CreateChirpReportParams{
+		ChirpID:       uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpAuthorID: chirp.UserID,
+		ChirpBody:     chirp.Body,
+		ReporterID:    userID,
+		Reason:        request.Reason,
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		http.Error(w, "You have already reported this chirp", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to report chirp", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(mapReportToResponse(report))
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleAdminListReports(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && status != ReportStatusOpen && status != ReportStatusDismissed && status != ReportStatusActioned {
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reports, err := cfg.Db.GetChirpReports(r.Context(), database.GetChirpReportsParams{
+		Column1: status,
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error retrieving reports", "error", err)
+		http.Error(w, "Failed to retrieve reports", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponseReport{}
+	for _, report := range reports {
+		response = append(response, mapReportToResponse(report))
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleAdminResolveReport(w http.ResponseWriter, r *http.Request) {
+	var request RequestResolveReport
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	status := ReportStatusActioned
+	switch request.Action {
+	case ReportActionDismiss:
+		status = ReportStatusDismissed
+	case ReportActionHide, ReportActionDelete:
+	case ReportActionSuspend:
+		if request.SuspendUntil != nil && !request.SuspendUntil.After(time.Now()) {
+			http.Error(w, "suspend_until must be in the future", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Action must be one of dismiss, hide, delete or suspend", http.StatusBadRequest)
+		return
+	}
+
+	report, err := cfg.Db.GetChirpReportByID(r.Context(), reportID)
+	if err != nil {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+
+	if report.Status != ReportStatusOpen {
+		http.Error(w, "Report has already been resolved", http.StatusConflict)
+		return
+	}
+
+	adminID := adminIDFromContext(r.Context())
+	if report.ChirpAuthorID == adminID {
+		http.Error(w, "Reports about your own chirps must be resolved by another admin", http.StatusForbidden)
+		return
+	}
+
+	resolution := sql.NullString{String: request.Action, Valid: true}
+	note := sql.NullString{String: request.Note, Valid: request.Note != ""}
+	resolvedBy := uuid.NullUUID{UUID: adminID, Valid: true}
+
+	err = cfg.InTx(r.Context(), func(q database.Querier) error {
+		// Claiming the report first makes a concurrent resolution wait on the
+		// row and then find it no longer open, so only one action is applied.
+		var err error
+		report, err = q.ResolveChirpReport(r.Context(), database.ResolveChirpReportParams{
+			Status:         status,
+			Resolution:     resolution,
+			ResolutionNote: note,
+			ResolvedBy:     resolvedBy,
+			ID:             report.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Other open reports about the same chirp are settled by hiding or
+		// deleting it, so resolve them first: deleting clears their chirp_id.
+		resolveOthers := func() error {
+			if !report.ChirpID.Valid {
+				return nil
+			}
+			return q.ResolveOpenReportsForChirp(r.Context(), database.ResolveOpenReportsForChirpParams{
+				Status:         status,
+				Resolution:     resolution,
+				ResolutionNote: note,
+				ResolvedBy:     resolvedBy,
+				ChirpID:        report.ChirpID,
+			})
+		}
+
+		switch request.Action {
+		case ReportActionHide:
+			if report.ChirpID.Valid {
+				err = q.HideChirp(r.Context(), report.ChirpID.UUID)
+			}
+			if err == nil {
+				err = resolveOthers()
+			}
+		case ReportActionDelete:
+			err = resolveOthers()
+			if err == nil && report.ChirpID.Valid {
+				err = q.DeleteChirpByID(r.Context(), report.ChirpID.UUID)
+			}
+		case ReportActionSuspend:
+			expiresAt := sql.NullTime{}
+			if request.SuspendUntil != nil {
+				expiresAt = sql.NullTime{Time: *request.SuspendUntil, Valid: true}
+			}
+
+			reason := request.Note
+			if reason == "" {
+				reason = "Reported chirp: " + report.Reason
+			}
+
+			_, err = cfg.applyUserStatus(r.Context(), q, report.ChirpAuthorID, UserStatusSuspended, reason, expiresAt)
+		}
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Report has already been resolved", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error applying moderation action", "error", err)
+		http.Error(w, "Failed to apply moderation action", http.StatusInternalServerError)
+		return
+	}
+
+	if (request.Action == ReportActionHide || request.Action == ReportActionDelete) && report.ChirpID.Valid {
+		cfg.publishChirpEvent(r.Context(), ChirpEventDeleted, database.Chirp{
+			ID:     report.ChirpID.UUID,
+			UserID: report.ChirpAuthorID,
+		})
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(mapReportToResponse(report))
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
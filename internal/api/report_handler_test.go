@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+// reportDB holds a single report and records the moderation actions taken.
+type reportDB struct {
+	database.Querier
+	report database.ChirpReport
+
+	hidden    int
+	suspended int
+}
+
+func (db *reportDB) GetChirpReportByID(ctx context.Context, id uuid.UUID) (database.ChirpReport, error) {
+	if id != db.report.ID {
+		return database.ChirpReport{}, sql.ErrNoRows
+	}
+	return db.report, nil
+}
+
+func (db *reportDB) ResolveChirpReport(ctx context.Context, arg database.ResolveChirpReportParams) (database.ChirpReport, error) {
+	if arg.ID != db.report.ID || db.report.Status != ReportStatusOpen {
+		return database.ChirpReport{}, sql.ErrNoRows
+	}
+	db.report.Status = arg.Status
+	db.report.Resolution = arg.Resolution
+	return db.report, nil
+}
+
+func (db *reportDB) ResolveOpenReportsForChirp(ctx context.Context, arg database.ResolveOpenReportsForChirpParams) error {
+	return nil
+}
+
+func (db *reportDB) HideChirp(ctx context.Context, id uuid.UUID) error {
+	db.hidden++
+	return nil
+}
+
+func (db *reportDB) SetUserStatus(ctx context.Context, arg database.SetUserStatusParams) (database.User, error) {
+	db.suspended++
+	return database.User{ID: arg.ID, Status: arg.Status}, nil
+}
+
+func (db *reportDB) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	return nil
+}
+
+func (db *reportDB) CreateUserAuditLog(ctx context.Context, arg database.CreateUserAuditLogParams) error {
+	return nil
+}
+
+func resolveReport(t *testing.T, cfg *Api, reportID, adminID uuid.UUID, body string) int {
+	t.Helper()
+
+	req := newRequest(t, http.MethodPost, "/admin/reports/"+reportID.String()+"/resolve", body, uuid.Nil)
+	req.SetPathValue("reportID", reportID.String())
+	req = req.WithContext(context.WithValue(req.Context(), adminIDKey, adminID))
+
+	rec := httptest.NewRecorder()
+	cfg.handleAdminResolveReport(rec, req)
+	return rec.Code
+}
+
+func TestHandleAdminResolveReport(t *testing.T) {
+	adminID := uuid.New()
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name          string
+		authorID      uuid.UUID
+		status        string
+		body          string
+		wantStatus    int
+		wantSuspended int
+	}{
+		{name: "Suspend until a future time", status: ReportStatusOpen, body: `{"action":"suspend","suspend_until":"` + future + `"}`, wantStatus: http.StatusOK, wantSuspended: 1},
+		{name: "Suspend until a past time", status: ReportStatusOpen, body: `{"action":"suspend","suspend_until":"` + past + `"}`, wantStatus: http.StatusBadRequest},
+		{name: "Own chirp", authorID: adminID, status: ReportStatusOpen, body: `{"action":"dismiss"}`, wantStatus: http.StatusForbidden},
+		{name: "Already resolved", status: ReportStatusDismissed, body: `{"action":"suspend"}`, wantStatus: http.StatusConflict},
+		{name: "Unknown action", status: ReportStatusOpen, body: `{"action":"ban"}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorID := tt.authorID
+			if authorID == uuid.Nil {
+				authorID = uuid.New()
+			}
+			db := &reportDB{report: database.ChirpReport{
+				ID:            uuid.New(),
+				ChirpID:       uuid.NullUUID{UUID: uuid.New(), Valid: true},
+				ChirpAuthorID: authorID,
+				Status:        tt.status,
+			}}
+			cfg := &Api{Db: db, InTx: inlineTx(db), DisableOutgoingWebhooks: true}
+
+			if got := resolveReport(t, cfg, db.report.ID, adminID, tt.body); got != tt.wantStatus {
+				t.Errorf("status = %d, want %d", got, tt.wantStatus)
+			}
+			if db.suspended != tt.wantSuspended {
+				t.Errorf("suspended %d times, want %d", db.suspended, tt.wantSuspended)
+			}
+		})
+	}
+}
+
+// A report claimed by another admin between the read and the update must not
+// have its action applied a second time.
+func TestHandleAdminResolveReportRace(t *testing.T) {
+	db := &racingReportDB{reportDB: reportDB{report: database.ChirpReport{
+		ID:            uuid.New(),
+		ChirpID:       uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		ChirpAuthorID: uuid.New(),
+		Status:        ReportStatusOpen,
+	}}}
+	cfg := &Api{Db: db, InTx: inlineTx(db), DisableOutgoingWebhooks: true}
+
+	if got := resolveReport(t, cfg, db.report.ID, uuid.New(), `{"action":"hide"}`); got != http.StatusConflict {
+		t.Errorf("status = %d, want %d", got, http.StatusConflict)
+	}
+	if db.hidden != 0 {
+		t.Errorf("chirp hidden %d times, want 0", db.hidden)
+	}
+}
+
+// racingReportDB lets another admin resolve the report right after it is read.
+type racingReportDB struct {
+	reportDB
+}
+
+func (db *racingReportDB) GetChirpReportByID(ctx context.Context, id uuid.UUID) (database.ChirpReport, error) {
+	report, err := db.reportDB.GetChirpReportByID(ctx, id)
+	db.report.Status = ReportStatusActioned
+	return report, err
+}
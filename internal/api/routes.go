@@ -1,6 +1,10 @@
 package api
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/joaogiacometti/goserver/internal/tracing"
+)
 
 func (apiCfg *Api) BindRoutes() http.Handler {
 	fileServerHandler := http.StripPrefix("/app", http.FileServer(http.Dir(".")))
@@ -8,23 +12,100 @@ func (apiCfg *Api) BindRoutes() http.Handler {
 	serveMux := http.NewServeMux()
 	serveMux.Handle("/app/", apiCfg.middlewareMetricsInc(fileServerHandler))
 
-	serveMux.HandleFunc("POST /admin/reset", apiCfg.handleResetHitsCount)
+	serveMux.HandleFunc("POST /admin/reset", apiCfg.middlewareAdmin(apiCfg.handleResetHitsCount))
 	serveMux.HandleFunc("GET /api/healthz", handleHealth)
-	serveMux.HandleFunc("GET /admin/metrics", apiCfg.handleHitsCount)
+	serveMux.HandleFunc("GET /livez", handleLivez)
+	serveMux.HandleFunc("GET /readyz", apiCfg.handleReadyz)
+	serveMux.Handle("GET /metrics", apiCfg.middlewareMetricsToken(apiCfg.Metrics.Handler()))
+	serveMux.HandleFunc("GET /admin/metrics", apiCfg.middlewareAdmin(apiCfg.handleHitsCount))
+
+	serveMux.HandleFunc("GET /admin/users", apiCfg.middlewareAdmin(apiCfg.handleAdminListUsers))
+	serveMux.HandleFunc("POST /admin/users/{userID}/suspend", apiCfg.middlewareAdmin(apiCfg.handleAdminSuspendUser))
+	serveMux.HandleFunc("POST /admin/users/{userID}/ban", apiCfg.middlewareAdmin(apiCfg.handleAdminBanUser))
+	serveMux.HandleFunc("POST /admin/users/{userID}/reinstate", apiCfg.middlewareAdmin(apiCfg.handleAdminReinstateUser))
+	serveMux.HandleFunc("GET /admin/users/{userID}/audit", apiCfg.middlewareAdmin(apiCfg.handleAdminUserAudit))
+
+	serveMux.HandleFunc("GET /admin/reports", apiCfg.middlewareAdmin(apiCfg.handleAdminListReports))
+	serveMux.HandleFunc("POST /admin/reports/{reportID}/resolve", apiCfg.middlewareAdmin(apiCfg.handleAdminResolveReport))
+	serveMux.HandleFunc("POST /admin/users/{userID}/promote", apiCfg.middlewareAdmin(apiCfg.handleAdminPromoteUser))
+	serveMux.HandleFunc("POST /admin/users/{userID}/demote", apiCfg.middlewareAdmin(apiCfg.handleAdminDemoteUser))
+	serveMux.HandleFunc("GET /admin/webhooks", apiCfg.middlewareAdmin(apiCfg.handleAdminListWebhooks))
+	serveMux.HandleFunc("POST /admin/webhooks/{eventID}/replay", apiCfg.middlewareAdmin(apiCfg.handleAdminReplayWebhook))
 
 	serveMux.HandleFunc("POST /api/users", apiCfg.handleCreateUser)
 	serveMux.HandleFunc("PUT /api/users", apiCfg.handleUpdateUser)
+	serveMux.HandleFunc("DELETE /api/users", apiCfg.handleDeleteUser)
+	serveMux.HandleFunc("GET /api/users/export", apiCfg.handleExportUser)
+	serveMux.HandleFunc("POST /api/users/2fa", apiCfg.handleEnrollTotp)
+	serveMux.HandleFunc("POST /api/users/2fa/confirm", apiCfg.handleConfirmTotp)
+	serveMux.HandleFunc("DELETE /api/users/2fa", apiCfg.handleDisableTotp)
+	serveMux.HandleFunc("GET /api/users/passkeys", apiCfg.handleGetPasskeys)
+	serveMux.HandleFunc("POST /api/users/passkeys/begin", apiCfg.handleBeginPasskeyRegistration)
+	serveMux.HandleFunc("POST /api/users/passkeys/finish", apiCfg.handleFinishPasskeyRegistration)
+	serveMux.HandleFunc("DELETE /api/users/passkeys/{passkeyID}", apiCfg.handleDeletePasskey)
+	serveMux.HandleFunc("GET /api/users/blocks", apiCfg.handleGetBlocks)
+	serveMux.HandleFunc("GET /api/users/mutes", apiCfg.handleGetMutes)
+	serveMux.HandleFunc("POST /api/users/blocks/{userID}", apiCfg.handleBlockUser)
+	serveMux.HandleFunc("DELETE /api/users/blocks/{userID}", apiCfg.handleUnblockUser)
+	serveMux.HandleFunc("POST /api/users/mutes/{userID}", apiCfg.handleMuteUser)
+	serveMux.HandleFunc("DELETE /api/users/mutes/{userID}", apiCfg.handleUnmuteUser)
+	serveMux.HandleFunc("PUT /api/users/privacy", apiCfg.handleSetPrivacy)
+	serveMux.HandleFunc("POST /api/users/follows/{userID}", apiCfg.handleFollowUser)
+	serveMux.HandleFunc("DELETE /api/users/follows/{userID}", apiCfg.handleUnfollowUser)
+	serveMux.HandleFunc("GET /api/users/follow-requests", apiCfg.handleGetFollowRequests)
+	serveMux.HandleFunc("POST /api/users/follow-requests/{userID}/approve", apiCfg.handleApproveFollowRequest)
+	serveMux.HandleFunc("POST /api/users/follow-requests/{userID}/deny", apiCfg.handleDenyFollowRequest)
+	serveMux.HandleFunc("GET /api/users/notification-preferences", apiCfg.handleGetNotificationPreferences)
+	serveMux.HandleFunc("PUT /api/users/notification-preferences", apiCfg.handleUpdateNotificationPreferences)
+	serveMux.HandleFunc("GET /api/users/entitlements", apiCfg.handleGetEntitlements)
+
+	serveMux.HandleFunc("GET /api/notifications", apiCfg.handleGetNotifications)
+	serveMux.HandleFunc("GET /api/notifications/unread-count", apiCfg.handleGetUnreadNotificationCount)
+	serveMux.HandleFunc("POST /api/notifications/read", apiCfg.handleMarkAllNotificationsRead)
+	serveMux.HandleFunc("POST /api/notifications/{notificationID}/read", apiCfg.handleMarkNotificationRead)
+
+	serveMux.HandleFunc("POST /api/conversations", apiCfg.handleCreateConversation)
+	serveMux.HandleFunc("GET /api/conversations", apiCfg.handleGetConversations)
+	serveMux.HandleFunc("GET /api/conversations/{conversationID}/messages", apiCfg.handleGetMessages)
+	serveMux.HandleFunc("POST /api/conversations/{conversationID}/messages", apiCfg.handleSendMessage)
+	serveMux.HandleFunc("POST /api/conversations/{conversationID}/read", apiCfg.handleMarkConversationRead)
 
 	serveMux.HandleFunc("POST /api/chirps", apiCfg.handleCreateChirp)
 	serveMux.HandleFunc("GET /api/chirps", apiCfg.handleGetChirps)
 	serveMux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handleGetChirp)
 	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handleDeleteChirp)
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/reports", apiCfg.handleReportChirp)
+	serveMux.HandleFunc("GET /api/chirps/stream", apiCfg.handleChirpStream)
+	serveMux.HandleFunc("GET /api/ws", apiCfg.handleWebSocket)
 
 	serveMux.HandleFunc("POST /api/login", apiCfg.handleLogin)
+	serveMux.HandleFunc("POST /api/login/2fa", apiCfg.handleLoginMFA)
+	serveMux.HandleFunc("POST /api/login/passkey/begin", apiCfg.handleBeginPasskeyLogin)
+	serveMux.HandleFunc("POST /api/login/passkey/finish", apiCfg.handleFinishPasskeyLogin)
+	serveMux.HandleFunc("GET /api/login/oidc/{provider}", apiCfg.handleOIDCLogin)
+	serveMux.HandleFunc("GET /api/login/oidc/{provider}/callback", apiCfg.handleOIDCCallback)
 	serveMux.HandleFunc("POST /api/refresh", apiCfg.handleRefreshToken)
 	serveMux.HandleFunc("POST /api/revoke", apiCfg.handleRevoke)
 
+	serveMux.HandleFunc("POST /api/oauth/clients", apiCfg.handleCreateOAuthClient)
+	serveMux.HandleFunc("GET /api/oauth/clients", apiCfg.handleGetOAuthClients)
+	serveMux.HandleFunc("DELETE /api/oauth/clients/{clientID}", apiCfg.handleDeleteOAuthClient)
+
+	serveMux.HandleFunc("GET /oauth/authorize", apiCfg.handleOAuthAuthorize)
+	serveMux.HandleFunc("POST /oauth/authorize", apiCfg.handleOAuthConsent)
+	serveMux.HandleFunc("POST /oauth/token", apiCfg.handleOAuthToken)
+	serveMux.HandleFunc("POST /oauth/introspect", apiCfg.handleOAuthIntrospect)
+	serveMux.HandleFunc("POST /oauth/revoke", apiCfg.handleOAuthRevoke)
+	serveMux.HandleFunc("GET /oauth/userinfo", apiCfg.handleOAuthUserInfo)
+
 	serveMux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlePolkaWebhooks)
 
-	return serveMux
+	serveMux.HandleFunc("POST /api/webhooks", apiCfg.handleCreateWebhookEndpoint)
+	serveMux.HandleFunc("GET /api/webhooks", apiCfg.handleGetWebhookEndpoints)
+	serveMux.HandleFunc("DELETE /api/webhooks/{endpointID}", apiCfg.handleDeleteWebhookEndpoint)
+	serveMux.HandleFunc("POST /api/webhooks/{endpointID}/enable", apiCfg.handleEnableWebhookEndpoint)
+	serveMux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries", apiCfg.handleGetWebhookDeliveries)
+	serveMux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries/{deliveryID}/attempts", apiCfg.handleGetWebhookDeliveryAttempts)
+
+	return tracing.Middleware(middlewareRequestID(apiCfg.middlewareAccessLog(apiCfg.middlewareRequestMetrics(apiCfg.middlewareMaxBodySize(apiCfg.middlewareRateLimit(tracing.RouteSpans(serveMux)))))))
 }
@@ -0,0 +1,15 @@
+package api
+
+import "testing"
+
+// ServeMux panics on conflicting patterns, so registering every route is
+// enough to catch overlaps before the server starts.
+func TestBindRoutes(t *testing.T) {
+	defer func() {
+		if err := recover(); err != nil {
+			t.Fatalf("BindRoutes() panicked: %v", err)
+		}
+	}()
+
+	(&Api{}).BindRoutes()
+}
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+	"github.com/joaogiacometti/goserver/internal/stream"
+)
+
+const (
+	ChirpEventCreated = "chirp.created"
+	ChirpEventDeleted = "chirp.deleted"
+
+	// StreamEventReset tells a resuming client that the events it missed
+	// cannot be replayed.
+	StreamEventReset = "reset"
+)
+
+var StreamHeartbeatInterval = 15 * time.Second
+
+type ResponseChirpDeleted struct {
+	Id     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+// publishChirpEvent pushes a chirp change to stream subscribers and queues it
+// for webhook endpoints. Hidden chirps are published as deletions.
+func (cfg *Api) publishChirpEvent(ctx context.Context, eventType string, chirp database.Chirp) {
+	var payload any = MapChirpToResponse(chirp)
+	if eventType == ChirpEventDeleted {
+		payload = ResponseChirpDeleted{
+			Id:     chirp.ID.String(),
+			UserID: chirp.UserID.String(),
+		}
+	}
+
+	cfg.queueWebhookEvent(ctx, eventType, chirp.UserID, payload)
+
+	if cfg.ChirpStream == nil {
+		return
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "error encoding chirp event", "error", err)
+		return
+	}
+
+	cfg.ChirpStream.Publish(stream.Event{
+		Type:   eventType,
+		UserID: chirp.UserID,
+		Data:   data,
+	})
+}
+
+// streamFilter decides which chirp events a stream subscriber receives. It
+// applies the same banned-author, blocks, mutes and private-account rules as
+// handleGetChirps. Answers are cached per author and everything is reloaded
+// after StreamFilterTTL, so blocks, bans and follow changes made while the
+// stream is open take effect within that time.
+type streamFilter struct {
+	cfg           *Api
+	viewerID      uuid.UUID
+	authorID      uuid.UUID
+	followingOnly bool
+	following     map[uuid.UUID]bool
+	muted         map[uuid.UUID]bool
+	allowed       map[uuid.UUID]bool
+	loadedAt      time.Time
+}
+
+var StreamFilterTTL = time.Minute
+
+func (f *streamFilter) accepts(ctx context.Context, event stream.Event) bool {
+	if f.authorID != uuid.Nil && event.UserID != f.authorID {
+		return false
+	}
+
+	if time.Since(f.loadedAt) >= StreamFilterTTL {
+		err := f.load(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "error refreshing stream filter", "error", err)
+			return false
+		}
+	}
+
+	if f.following != nil && !f.following[event.UserID] {
+		return false
+	}
+	if f.muted[event.UserID] {
+		return false
+	}
+
+	if allowed, ok := f.allowed[event.UserID]; ok {
+		return allowed
+	}
+
+	author, err := f.cfg.Db.GetUserByID(ctx, event.UserID)
+	if err != nil {
+		return false
+	}
+	if author.Status == UserStatusBanned {
+		f.allowed[event.UserID] = false
+		return false
+	}
+
+	blocked, err := f.cfg.Db.IsBlockedBetween(ctx, database.IsBlockedBetweenParams{
+		BlockerID: event.UserID,
+		BlockedID: f.viewerID,
+	})
+	if err != nil {
+		return false
+	}
+
+	visible, err := f.cfg.Db.CanViewUserChirps(ctx, database.CanViewUserChirpsParams{
+		AuthorID: event.UserID,
+		ViewerID: f.viewerID,
+	})
+	if err != nil {
+		return false
+	}
+
+	f.allowed[event.UserID] = visible && !blocked
+	return f.allowed[event.UserID]
+}
+
+// load reads the viewer's mutes and follows and forgets cached answers.
+func (f *streamFilter) load(ctx context.Context) error {
+	muted := map[uuid.UUID]bool{}
+	var following map[uuid.UUID]bool
+
+	if f.viewerID != uuid.Nil {
+		mutes, err := f.cfg.Db.GetMutedUsers(ctx, f.viewerID)
+		if err != nil {
+			return err
+		}
+		for _, mute := range mutes {
+			muted[mute.MutedID] = true
+		}
+
+		if f.followingOnly {
+			follows, err := f.cfg.Db.GetFollowing(ctx, database.GetFollowingParams{
+				FollowerID: f.viewerID,
+				Status:     FollowStatusAccepted,
+			})
+			if err != nil {
+				return err
+			}
+
+			following = map[uuid.UUID]bool{}
+			for _, follow := range follows {
+				following[follow.FolloweeID] = true
+			}
+		}
+	}
+
+	f.muted = muted
+	f.following = following
+	f.allowed = map[uuid.UUID]bool{}
+	f.loadedAt = time.Now()
+	return nil
+}
+
+func (cfg *Api) newStreamFilter(ctx context.Context, viewerID, authorID uuid.UUID, followingOnly bool) (*streamFilter, error) {
+	filter := &streamFilter{
+		cfg:           cfg,
+		viewerID:      viewerID,
+		authorID:      authorID,
+		followingOnly: followingOnly,
+	}
+
+	err := filter.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// handleChirpStream pushes chirp events as Server-Sent Events. Clients that
+// reconnect with Last-Event-ID receive the retained events they missed. An ID
+// from before a restart cannot be resumed; those clients get a reset event
+// instead and should reload what they show.
+func (cfg *Api) handleChirpStream(w http.ResponseWriter, r *http.Request) {
+	if cfg.ChirpStream == nil {
+		http.Error(w, "Streaming is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	followingOnly := r.URL.Query().Get("following") == "true"
+	if followingOnly && viewerID == uuid.Nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	authorID := uuid.Nil
+	if value := r.URL.Query().Get("author_id"); value != "" {
+		authorID, err = uuid.Parse(value)
+		if err != nil {
+			http.Error(w, "Invalid author ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	var resumeFrom uint64
+	reset := false
+	if lastEventID != "" {
+		resumeFrom, err = cfg.ChirpStream.ParseEventID(lastEventID)
+		if errors.Is(err, stream.ErrUnknownEpoch) {
+			reset = true
+		} else if err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	filter, err := cfg.newStreamFilter(r.Context(), viewerID, authorID, followingOnly)
+	if err != nil {
+		http.Error(w, "Failed to open stream", http.StatusInternalServerError)
+		return
+	}
+
+	sub, missed := cfg.ChirpStream.Subscribe(resumeFrom)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.Header().Set("connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	if reset {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", StreamEventReset)
+	}
+	for _, event := range missed {
+		if filter.accepts(r.Context(), event) {
+			writeStreamEvent(w, cfg.ChirpStream, event)
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(StreamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-cfg.ShuttingDown:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case event, ok := <-sub.Events:
+			// The hub closes the channel when we fall too far behind. The
+			// client reconnects and resumes from its Last-Event-ID.
+			if !ok {
+				return
+			}
+			if !filter.accepts(r.Context(), event) {
+				continue
+			}
+			writeStreamEvent(w, cfg.ChirpStream, event)
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeStreamEvent(w io.Writer, hub *stream.Hub, event stream.Event) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", hub.EventID(event), event.Type, event.Data)
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/stream"
+)
+
+func TestStreamFilterAccepts(t *testing.T) {
+	tests := []struct {
+		name     string
+		private  bool
+		banned   bool
+		deleting bool
+		blocked  bool
+		muted    bool
+		want     bool
+	}{
+		{name: "Public author", want: true},
+		{name: "Banned author", banned: true, want: false},
+		{name: "Author pending deletion", deleting: true, want: false},
+		{name: "Author blocked the viewer", blocked: true, want: false},
+		{name: "Muted author", muted: true, want: false},
+		{name: "Private author the viewer does not follow", private: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newSocialDB()
+			authorID := db.addUser(tt.private)
+			viewerID := db.addUser(false)
+			if tt.banned {
+				author := db.users[authorID]
+				author.Status = UserStatusBanned
+				db.users[authorID] = author
+			}
+			if tt.deleting {
+				author := db.users[authorID]
+				author.DeleteAfter = sql.NullTime{Time: time.Now().Add(AccountDeletionGracePeriod), Valid: true}
+				db.users[authorID] = author
+			}
+			if tt.blocked {
+				db.blocks[userPair{authorID, viewerID}] = true
+			}
+			if tt.muted {
+				db.mutes[userPair{viewerID, authorID}] = true
+			}
+			cfg := &Api{Db: db}
+
+			filter, err := cfg.newStreamFilter(context.Background(), viewerID, uuid.Nil, false)
+			if err != nil {
+				t.Fatalf("newStreamFilter() error = %v", err)
+			}
+
+			// Ask twice so the cached answer is checked too.
+			for range 2 {
+				if got := filter.accepts(context.Background(), stream.Event{UserID: authorID}); got != tt.want {
+					t.Fatalf("accepts() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamFilterRefreshes(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(db *socialDB, authorID, viewerID uuid.UUID)
+	}{
+		{name: "Author blocks the viewer", change: func(db *socialDB, authorID, viewerID uuid.UUID) {
+			db.blocks[userPair{authorID, viewerID}] = true
+		}},
+		{name: "Viewer mutes the author", change: func(db *socialDB, authorID, viewerID uuid.UUID) {
+			db.mutes[userPair{viewerID, authorID}] = true
+		}},
+		{name: "Author is banned", change: func(db *socialDB, authorID, viewerID uuid.UUID) {
+			author := db.users[authorID]
+			author.Status = UserStatusBanned
+			db.users[authorID] = author
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newSocialDB()
+			authorID := db.addUser(false)
+			viewerID := db.addUser(false)
+			cfg := &Api{Db: db}
+
+			filter, err := cfg.newStreamFilter(context.Background(), viewerID, uuid.Nil, false)
+			if err != nil {
+				t.Fatalf("newStreamFilter() error = %v", err)
+			}
+			event := stream.Event{UserID: authorID}
+			if !filter.accepts(context.Background(), event) {
+				t.Fatal("accepts() = false before the change")
+			}
+
+			tt.change(db, authorID, viewerID)
+			filter.loadedAt = time.Now().Add(-StreamFilterTTL)
+
+			if filter.accepts(context.Background(), event) {
+				t.Error("accepts() = true after the filter refreshed")
+			}
+		})
+	}
+}
+
+func TestHandleChirpStreamResume(t *testing.T) {
+	db := newSocialDB()
+	authorID := db.addUser(false)
+	hub := stream.NewHub(10, 4)
+	var published []stream.Event
+	for range 3 {
+		published = append(published, hub.Publish(stream.Event{Type: ChirpEventCreated, UserID: authorID, Data: []byte(`{}`)}))
+	}
+	cfg := &Api{Db: db, ChirpStream: hub}
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		wantStatus  int
+		wantIDs     []string
+		wantReset   bool
+	}{
+		{name: "Fresh connection", wantStatus: http.StatusOK},
+		{
+			name:        "Resume",
+			lastEventID: hub.EventID(published[0]),
+			wantStatus:  http.StatusOK,
+			wantIDs:     []string{hub.EventID(published[1]), hub.EventID(published[2])},
+		},
+		{name: "ID from before a restart", lastEventID: "0-1", wantStatus: http.StatusOK, wantReset: true},
+		{name: "Malformed ID", lastEventID: "1", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			req := httptest.NewRequest(http.MethodGet, "/api/chirps/stream", nil).WithContext(ctx)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			rec := httptest.NewRecorder()
+			cfg.handleChirpStream(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var ids []string
+			for _, line := range strings.Split(rec.Body.String(), "\n") {
+				if id, ok := strings.CutPrefix(line, "id: "); ok {
+					ids = append(ids, id)
+				}
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("replayed IDs = %v, want %v", ids, tt.wantIDs)
+			}
+			if got := strings.Contains(rec.Body.String(), "event: "+StreamEventReset+"\n"); got != tt.wantReset {
+				t.Errorf("reset sent = %v, want %v", got, tt.wantReset)
+			}
+		})
+	}
+}
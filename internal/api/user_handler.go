@@ -1,7 +1,12 @@
 package api
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -24,15 +29,22 @@ type RequestUser struct {
 
 func mapUserToResponse(user database.User) ResponseLogin {
 	return ResponseLogin{
-		ID:          user.ID.String(),
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
+		ID:              user.ID.String(),
+		Email:           user.Email,
+		IsChirpyRed:     user.IsChirpyRed,
+		IsPrivate:       user.IsPrivate,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		RedSubscription: mapRedSubscription(user),
 	}
 }
 
 func (cfg *Api) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	if cfg.DisableSignups {
+		http.Error(w, "Signups are disabled", http.StatusForbidden)
+		return
+	}
+
 	var request RequestUser
 
 	err := json.NewDecoder(r.Body).Decode(&request)
@@ -117,3 +129,240 @@ func (cfg *Api) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+type RequestDeleteUser struct {
+	Password string `json:"password"`
+}
+
+type ResponseDeleteUser struct {
+	DeleteAfter          time.Time `json:"delete_after"`
+	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+}
+
+type ExportSession struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+type ResponseExport struct {
+	Profile    ResponseCreateUser `json:"profile"`
+	Chirps     []ResponseChrip    `json:"chirps"`
+	Sessions   []ExportSession    `json:"sessions"`
+	ExportedAt time.Time          `json:"exported_at"`
+}
+
+// AccountDeletionGracePeriod is how long a deleted account can still be
+// restored by logging in before it is purged for good.
+const AccountDeletionGracePeriod = time.Hour * 24 * 30
+
+// handleDeleteUser schedules the account for deletion. While the grace period
+// runs the user's chirps are hidden everywhere and their refresh tokens are
+// revoked. Access tokens are stateless and keep working until they expire, so
+// the response says when the one used here stops being accepted.
+func (cfg *Api) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	var request RequestDeleteUser
+
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	accessTokenExpiresAt, err := auth.AccessTokenExpiry(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	err = auth.CheckPasswordHash(request.Password, user.HashedPassword)
+	if err != nil {
+		http.Error(w, "Invalid password", http.StatusUnauthorized)
+		return
+	}
+
+	deleteAfter := time.Now().Add(AccountDeletionGracePeriod)
+
+	err = cfg.Db.ScheduleUserDeletion(r.Context(), database.ScheduleUserDeletionParams{
+		DeleteAfter: sql.NullTime{Time: deleteAfter, Valid: true},
+		ID:          user.ID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+
+	err = cfg.Db.RevokeAllForUser(r.Context(), user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error revoking refresh tokens", "error", err)
+	}
+
+	response := ResponseDeleteUser{
+		DeleteAfter:          deleteAfter,
+		AccessTokenExpiresAt: accessTokenExpiresAt,
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleExportUser(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	chirps, err := cfg.Db.GetChirpsByUserID(r.Context(), user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error retrieving chirps", "error", err)
+		http.Error(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := cfg.Db.GetRefreshTokensByUserID(r.Context(), user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error retrieving sessions", "error", err)
+		http.Error(w, "Failed to retrieve sessions", http.StatusInternalServerError)
+		return
+	}
+
+	export := ResponseExport{
+		Profile: ResponseCreateUser{
+			ID:          user.ID.String(),
+			Email:       user.Email,
+			IsChirpyRed: user.IsChirpyRed,
+			CreatedAt:   user.CreatedAt,
+			UpdatedAt:   user.UpdatedAt,
+		},
+		Chirps:     []ResponseChrip{},
+		Sessions:   []ExportSession{},
+		ExportedAt: time.Now().UTC(),
+	}
+
+	for _, chirp := range chirps {
+		export.Chirps = append(export.Chirps, MapChirpToResponse(chirp))
+	}
+
+	for _, token := range tokens {
+		session := ExportSession{
+			CreatedAt: token.CreatedAt,
+			ExpiresAt: token.ExpiresAt,
+		}
+		if token.RevokedAt.Valid {
+			session.RevokedAt = &token.RevokedAt.Time
+		}
+		export.Sessions = append(export.Sessions, session)
+	}
+
+	filename := "chirpy-export-" + user.ID.String()
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("content-type", "application/json")
+		w.Header().Set("content-disposition", `attachment; filename="`+filename+`.json"`)
+		w.WriteHeader(http.StatusOK)
+
+		err = json.NewEncoder(w).Encode(export)
+		if err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"profile.json", export.Profile},
+		{"chirps.json", export.Chirps},
+		{"sessions.json", export.Sessions},
+	}
+	for _, f := range files {
+		file, err := archive.Create(f.name)
+		if err != nil {
+			http.Error(w, "Failed to build export", http.StatusInternalServerError)
+			return
+		}
+
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(f.content)
+		if err != nil {
+			http.Error(w, "Failed to build export", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		http.Error(w, "Failed to build export", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/zip")
+	w.Header().Set("content-disposition", `attachment; filename="`+filename+`.zip"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// PurgeDeletedUsers permanently removes accounts whose deletion grace period
+// has elapsed. Chirps and refresh tokens go with them through ON DELETE CASCADE.
+func (cfg *Api) PurgeDeletedUsers(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := cfg.Db.DeleteUsersPastGracePeriod(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "error purging deleted users", "error", err)
+		} else if purged > 0 {
+			slog.InfoContext(ctx, "purged deleted users", "count", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+// accountDB serves a single user and records deletion scheduling.
+type accountDB struct {
+	database.Querier
+	user database.User
+
+	scheduled database.ScheduleUserDeletionParams
+	revoked   bool
+	cancelled bool
+	purges    int
+	refresh   database.CreateRefreshTokenParams
+}
+
+func (db *accountDB) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	if id != db.user.ID {
+		return database.User{}, sql.ErrNoRows
+	}
+	return db.user, nil
+}
+
+func (db *accountDB) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	if email != db.user.Email {
+		return database.User{}, sql.ErrNoRows
+	}
+	return db.user, nil
+}
+
+func (db *accountDB) ScheduleUserDeletion(ctx context.Context, arg database.ScheduleUserDeletionParams) error {
+	db.scheduled = arg
+	return nil
+}
+
+func (db *accountDB) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	db.revoked = true
+	return nil
+}
+
+func (db *accountDB) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	db.cancelled = true
+	return nil
+}
+
+func (db *accountDB) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) error {
+	db.refresh = arg
+	return nil
+}
+
+func (db *accountDB) GetRefreshTokenByToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	if token == "" || token != db.refresh.Token {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return database.RefreshToken{Token: token, UserID: db.refresh.UserID, ExpiresAt: db.refresh.ExpiresAt}, nil
+}
+
+func (db *accountDB) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	return []database.Chirp{{ID: uuid.New(), Body: "hello", UserID: userID}}, nil
+}
+
+func (db *accountDB) GetRefreshTokensByUserID(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+	return []database.RefreshToken{{Token: "secret-token", UserID: userID}}, nil
+}
+
+func (db *accountDB) DeleteUsersPastGracePeriod(ctx context.Context) (int64, error) {
+	db.purges++
+	return 0, nil
+}
+
+func newAccountDB(t *testing.T) *accountDB {
+	return &accountDB{user: database.User{
+		ID:             uuid.New(),
+		Email:          "walt@example.com",
+		HashedPassword: hashPassword(t, "04234"),
+		Status:         UserStatusActive,
+	}}
+}
+
+func TestHandleDeleteUser(t *testing.T) {
+	tests := []struct {
+		name         string
+		password     string
+		wantStatus   int
+		wantSchedule bool
+	}{
+		{name: "Schedules deletion", password: "04234", wantStatus: http.StatusAccepted, wantSchedule: true},
+		{name: "Wrong password", password: "wrong", wantStatus: http.StatusUnauthorized, wantSchedule: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newAccountDB(t)
+			cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+			rec := httptest.NewRecorder()
+			cfg.handleDeleteUser(rec, newRequest(t, http.MethodDelete, "/api/users", `{"password":"`+tt.password+`"}`, db.user.ID))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if db.scheduled.DeleteAfter.Valid != tt.wantSchedule || db.revoked != tt.wantSchedule {
+				t.Fatalf("scheduled = %v, sessions revoked = %v", db.scheduled.DeleteAfter.Valid, db.revoked)
+			}
+			if !tt.wantSchedule {
+				return
+			}
+
+			want := time.Now().Add(AccountDeletionGracePeriod)
+			if got := db.scheduled.DeleteAfter.Time; got.Before(want.Add(-time.Minute)) || got.After(want) {
+				t.Errorf("delete after = %v, want about %v", got, want)
+			}
+
+			var response ResponseDeleteUser
+			err := json.NewDecoder(rec.Body).Decode(&response)
+			if err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if !response.DeleteAfter.Equal(db.scheduled.DeleteAfter.Time) {
+				t.Errorf("response delete_after = %v, stored %v", response.DeleteAfter, db.scheduled.DeleteAfter.Time)
+			}
+			if until := time.Until(response.AccessTokenExpiresAt); until <= 0 || until > auth.DefaultDuration {
+				t.Errorf("access_token_expires_at = %v, want within %v", response.AccessTokenExpiresAt, auth.DefaultDuration)
+			}
+		})
+	}
+}
+
+func TestLoginCancelsPendingDeletion(t *testing.T) {
+	db := newAccountDB(t)
+	db.user.DeleteAfter = sql.NullTime{Time: time.Now().Add(time.Hour * 24), Valid: true}
+	cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+	rec := httptest.NewRecorder()
+	cfg.handleLogin(rec, newRequest(t, http.MethodPost, "/api/login", `{"email":"walt@example.com","password":"04234"}`, uuid.Nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+	}
+	if !db.cancelled {
+		t.Error("expected login to cancel the pending deletion")
+	}
+}
+
+func TestLoginUsesConfiguredTokenLifetimes(t *testing.T) {
+	db := newAccountDB(t)
+	cfg := &Api{Db: db, JwtTokenSecret: testSecret, AccessTokenTTL: time.Minute * 5, RefreshTokenTTL: time.Hour}
+
+	rec := httptest.NewRecorder()
+	cfg.handleLogin(rec, newRequest(t, http.MethodPost, "/api/login", `{"email":"walt@example.com","password":"04234"}`, uuid.Nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+	}
+
+	var response ResponseLogin
+	err := json.NewDecoder(rec.Body).Decode(&response)
+	if err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	claims, err := auth.ParseAccessToken(response.Token, testSecret)
+	if err != nil {
+		t.Fatalf("ParseAccessToken() error = %v", err)
+	}
+
+	if got := time.Until(claims.ExpiresAt.Time); got > time.Minute*5 || got < time.Minute*4 {
+		t.Errorf("access token expires in %v, want about 5m", got)
+	}
+	if got := time.Until(db.refresh.ExpiresAt); got > time.Hour || got < time.Minute*59 {
+		t.Errorf("refresh token expires in %v, want about 1h", got)
+	}
+}
+
+func TestHandleExportUser(t *testing.T) {
+	db := newAccountDB(t)
+	cfg := &Api{Db: db, JwtTokenSecret: testSecret}
+
+	t.Run("JSON", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		cfg.handleExportUser(rec, newRequest(t, http.MethodGet, "/api/users/export?format=json", "", db.user.ID))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d", rec.Code)
+		}
+		if got := rec.Header().Get("content-type"); got != "application/json" {
+			t.Errorf("content-type = %q", got)
+		}
+
+		var export ResponseExport
+		err := json.NewDecoder(rec.Body).Decode(&export)
+		if err != nil {
+			t.Fatalf("invalid export: %v", err)
+		}
+		if export.Profile.Email != db.user.Email || len(export.Chirps) != 1 || len(export.Sessions) != 1 {
+			t.Errorf("export = %+v", export)
+		}
+	})
+
+	t.Run("Zip", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		cfg.handleExportUser(rec, newRequest(t, http.MethodGet, "/api/users/export", "", db.user.ID))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d", rec.Code)
+		}
+		if got := rec.Header().Get("content-type"); got != "application/zip" {
+			t.Errorf("content-type = %q", got)
+		}
+
+		body := rec.Body.Bytes()
+		archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			t.Fatalf("invalid zip: %v", err)
+		}
+
+		files := map[string]bool{}
+		for _, file := range archive.File {
+			files[file.Name] = true
+
+			content, err := file.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(content)
+			content.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(data, []byte("secret-token")) {
+				t.Errorf("%s leaks a refresh token", file.Name)
+			}
+		}
+		for _, name := range []string{"profile.json", "chirps.json", "sessions.json"} {
+			if !files[name] {
+				t.Errorf("archive is missing %s", name)
+			}
+		}
+	})
+}
+
+func TestPurgeDeletedUsersStops(t *testing.T) {
+	db := newAccountDB(t)
+	cfg := &Api{Db: db}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		cfg.PurgeDeletedUsers(ctx, time.Hour)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("PurgeDeletedUsers did not return after its context was cancelled")
+	}
+	if db.purges != 1 {
+		t.Errorf("purges = %d, want 1", db.purges)
+	}
+}
@@ -0,0 +1,595 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+	"github.com/joaogiacometti/goserver/internal/webhook"
+)
+
+// WebhookEvents lists the events an endpoint can subscribe to. Endpoints
+// owned by a user only receive events about that user; global endpoints,
+// which only admins can register, receive them for everyone.
+var WebhookEvents = map[string]bool{
+	ChirpEventCreated: true,
+	ChirpEventDeleted: true,
+	UserUpgraded:      true,
+}
+
+const (
+	WebhookDeliveryPending    = "pending"
+	WebhookDeliveryProcessing = "processing"
+	WebhookDeliveryDelivered  = "delivered"
+	WebhookDeliveryFailed     = "failed"
+)
+
+const MaxWebhookEndpoints = 10
+
+// MaxWebhookDeliveryAttempts is how many times a delivery is sent before it
+// is given up on, and WebhookEndpointFailureLimit how many deliveries in a
+// row may be given up on before their endpoint is disabled.
+const (
+	MaxWebhookDeliveryAttempts  = 8
+	WebhookEndpointFailureLimit = 5
+)
+
+type RequestWebhookEndpoint struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Global bool     `json:"global"`
+}
+
+type ResponseWebhookEndpoint struct {
+	ID                  string     `json:"id"`
+	UserID              *string    `json:"user_id"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"`
+	Enabled             bool       `json:"enabled"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	Secret              string     `json:"secret,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+type ResponseWebhookDelivery struct {
+	ID             string          `json:"id"`
+	EndpointID     string          `json:"endpoint_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	LastStatusCode *int32          `json:"last_status_code"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type ResponseWebhookDeliveryAttempt struct {
+	ID          string    `json:"id"`
+	StatusCode  *int32    `json:"status_code"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int32     `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// WebhookPayload is the body posted to endpoints. ID identifies the event and
+// is shared by every endpoint it is delivered to.
+type WebhookPayload struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+func mapWebhookEndpointToResponse(endpoint database.WebhookEndpoint) ResponseWebhookEndpoint {
+	response := ResponseWebhookEndpoint{
+		ID:                  endpoint.ID.String(),
+		URL:                 endpoint.Url,
+		Events:              endpoint.Events,
+		Enabled:             endpoint.Enabled,
+		ConsecutiveFailures: endpoint.ConsecutiveFailures,
+		CreatedAt:           endpoint.CreatedAt,
+		UpdatedAt:           endpoint.UpdatedAt,
+	}
+	if endpoint.UserID.Valid {
+		userID := endpoint.UserID.UUID.String()
+		response.UserID = &userID
+	}
+	if endpoint.DisabledAt.Valid {
+		response.DisabledAt = &endpoint.DisabledAt.Time
+	}
+	return response
+}
+
+func mapWebhookDeliveryToResponse(delivery database.WebhookDelivery) ResponseWebhookDelivery {
+	response := ResponseWebhookDelivery{
+		ID:            delivery.ID.String(),
+		EndpointID:    delivery.EndpointID.String(),
+		Event:         delivery.Event,
+		Payload:       delivery.Payload,
+		Status:        delivery.Status,
+		Attempts:      delivery.Attempts,
+		LastError:     delivery.LastError.String,
+		NextAttemptAt: delivery.NextAttemptAt,
+		CreatedAt:     delivery.CreatedAt,
+	}
+	if delivery.LastStatusCode.Valid {
+		response.LastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	if delivery.DeliveredAt.Valid {
+		response.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+	return response
+}
+
+func mapWebhookDeliveryAttemptToResponse(attempt database.WebhookDeliveryAttempt) ResponseWebhookDeliveryAttempt {
+	response := ResponseWebhookDeliveryAttempt{
+		ID:          attempt.ID.String(),
+		Error:       attempt.Error.String,
+		DurationMs:  attempt.DurationMs,
+		AttemptedAt: attempt.AttemptedAt,
+	}
+	if attempt.StatusCode.Valid {
+		response.StatusCode = &attempt.StatusCode.Int32
+	}
+	return response
+}
+
+// queueWebhookEvent records a delivery for every enabled endpoint subscribed
+// to event that may see events about userID. Failures are logged rather than
+// returned so that webhooks never fail the change that triggered them.
+func (cfg *Api) queueWebhookEvent(ctx context.Context, event string, userID uuid.UUID, data any) {
+	if cfg.DisableOutgoingWebhooks {
+		return
+	}
+
+	payload, err := json.Marshal(WebhookPayload{
+		ID:        uuid.NewString(),
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "error encoding webhook payload", "error", err)
+		return
+	}
+
+	_, err = cfg.Db.CreateWebhookDeliveries(ctx, database.CreateWebhookDeliveriesParams{
+		Event:   event,
+		Payload: payload,
+		UserID:  userID,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "error queueing webhook deliveries", "error", err)
+	}
+}
+
+// DeliverWebhooks sends queued deliveries to their endpoints, retrying
+// failures with the same backoff as the webhook inbox. Deliveries to
+// disabled endpoints wait until the endpoint is enabled again.
+func (cfg *Api) DeliverWebhooks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			deliveries, err := cfg.Db.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+				NextAttemptAt: time.Now().Add(WebhookLease),
+				Limit:         WebhookBatchSize,
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "error claiming webhook deliveries", "error", err)
+				break
+			}
+
+			for _, delivery := range deliveries {
+				cfg.deliverWebhook(ctx, delivery)
+			}
+
+			if len(deliveries) < WebhookBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *Api) deliverWebhook(ctx context.Context, delivery database.WebhookDelivery) {
+	endpoint, err := cfg.Db.GetWebhookEndpointByID(ctx, delivery.EndpointID)
+	if err != nil {
+		slog.ErrorContext(ctx, "error retrieving webhook endpoint", "error", err)
+		return
+	}
+
+	client := cfg.WebhookClient
+	if client == nil {
+		client = webhook.DefaultClient
+	}
+
+	result, sendErr := webhook.Send(ctx, client, endpoint.Url, endpoint.Secret, webhook.Delivery{
+		ID:      delivery.ID,
+		Event:   delivery.Event,
+		Payload: delivery.Payload,
+	}, time.Now())
+
+	statusCode := sql.NullInt32{Int32: int32(result.StatusCode), Valid: result.StatusCode != 0}
+	var lastError sql.NullString
+	if sendErr != nil {
+		lastError = sql.NullString{String: sendErr.Error(), Valid: true}
+	}
+
+	err = cfg.Db.CreateWebhookDeliveryAttempt(ctx, database.CreateWebhookDeliveryAttemptParams{
+		DeliveryID: delivery.ID,
+		StatusCode: statusCode,
+		Error:      lastError,
+		DurationMs: int32(result.Duration.Milliseconds()),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "error recording webhook delivery attempt", "error", err)
+	}
+
+	if sendErr == nil {
+		cfg.Metrics.WebhookDelivered(delivery.Event, WebhookDeliveryDelivered)
+		err = cfg.Db.CompleteWebhookDelivery(ctx, database.CompleteWebhookDeliveryParams{
+			LastStatusCode: statusCode,
+			ID:             delivery.ID,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "error completing webhook delivery", "error", err)
+		}
+
+		err = cfg.Db.ResetWebhookEndpointFailures(ctx, endpoint.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "error resetting webhook endpoint failures", "error", err)
+		}
+		return
+	}
+
+	if delivery.Attempts < MaxWebhookDeliveryAttempts {
+		cfg.Metrics.WebhookDelivered(delivery.Event, "retry")
+		err = cfg.Db.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{
+			LastStatusCode: statusCode,
+			LastError:      lastError,
+			NextAttemptAt:  time.Now().Add(webhookRetryDelay(delivery.Attempts)),
+			ID:             delivery.ID,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "error rescheduling webhook delivery", "error", err)
+		}
+		return
+	}
+
+	cfg.Metrics.WebhookDelivered(delivery.Event, WebhookDeliveryFailed)
+	err = cfg.Db.FailWebhookDelivery(ctx, database.FailWebhookDeliveryParams{
+		LastStatusCode: statusCode,
+		LastError:      lastError,
+		ID:             delivery.ID,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "error failing webhook delivery", "error", err)
+	}
+
+	endpoint, err = cfg.Db.RecordWebhookEndpointFailure(ctx, database.RecordWebhookEndpointFailureParams{
+		MaxFailures: WebhookEndpointFailureLimit,
+		ID:          endpoint.ID,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "error recording webhook endpoint failure", "error", err)
+		return
+	}
+	if !endpoint.Enabled && endpoint.ConsecutiveFailures == WebhookEndpointFailureLimit {
+		slog.WarnContext(ctx, "disabled failing webhook endpoint", "endpoint_id", endpoint.ID, "failed_deliveries", endpoint.ConsecutiveFailures)
+	}
+}
+
+// webhookCaller authenticates the request and reports whether the caller may
+// manage global endpoints.
+func (cfg *Api) webhookCaller(r *http.Request) (database.User, bool, error) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return database.User{}, false, err
+	}
+
+	userID, err := auth.ValidateJWT(accessToken, cfg.JwtTokenSecret)
+	if err != nil {
+		return database.User{}, false, err
+	}
+
+	user, err := cfg.Db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		return database.User{}, false, err
+	}
+
+	return user, user.Role == RoleAdmin && accountRestriction(user) == "", nil
+}
+
+// webhookEndpointForRequest loads the endpoint named in the path if the
+// caller owns it, or it is global and the caller is an admin. It writes the
+// error response itself and returns false when the request cannot proceed.
+func (cfg *Api) webhookEndpointForRequest(w http.ResponseWriter, r *http.Request) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		http.Error(w, "Invalid endpoint ID", http.StatusBadRequest)
+		return database.WebhookEndpoint{}, false
+	}
+
+	user, isAdmin, err := cfg.webhookCaller(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return database.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := cfg.Db.GetWebhookEndpointByID(r.Context(), endpointID)
+	if err != nil {
+		http.Error(w, "Webhook endpoint not found", http.StatusNotFound)
+		return database.WebhookEndpoint{}, false
+	}
+
+	owned := endpoint.UserID.Valid && endpoint.UserID.UUID == user.ID
+	if !owned && (endpoint.UserID.Valid || !isAdmin) {
+		http.Error(w, "Webhook endpoint not found", http.StatusNotFound)
+		return database.WebhookEndpoint{}, false
+	}
+
+	return endpoint, true
+}
+
+func (cfg *Api) handleCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	var request RequestWebhookEndpoint
+
+	user, isAdmin, err := cfg.webhookCaller(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.Global && !isAdmin {
+		http.Error(w, "Only admins can register global webhook endpoints", http.StatusForbidden)
+		return
+	}
+
+	err = webhook.ValidateURL(request.URL, cfg.Platform == "dev")
+	if err != nil {
+		http.Error(w, "URL must be an absolute https URL with a host name", http.StatusBadRequest)
+		return
+	}
+
+	var events []string
+	for _, event := range request.Events {
+		if !WebhookEvents[event] {
+			http.Error(w, fmt.Sprintf("Unknown event %q", event), http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		http.Error(w, "At least one event is required", http.StatusBadRequest)
+		return
+	}
+	slices.Sort(events)
+
+	owner := uuid.NullUUID{UUID: user.ID, Valid: true}
+	if request.Global {
+		owner = uuid.NullUUID{}
+	}
+
+	existing, err := cfg.Db.GetWebhookEndpoints(r.Context(), database.GetWebhookEndpointsParams{
+		UserID:  owner,
+		Column2: request.Global,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error retrieving webhook endpoints", "error", err)
+		http.Error(w, "Failed to create webhook endpoint", http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= MaxWebhookEndpoints {
+		http.Error(w, fmt.Sprintf("At most %d webhook endpoints can be registered", MaxWebhookEndpoints), http.StatusConflict)
+		return
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		http.Error(w, "Failed to create webhook endpoint", http.StatusInternalServerError)
+		return
+	}
+
+	endpoint, err := cfg.Db.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID: owner,
+		Url:    request.URL,
+		Secret: secret,
+		Events: events,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating webhook endpoint", "error", err)
+		http.Error(w, "Failed to create webhook endpoint", http.StatusInternalServerError)
+		return
+	}
+
+	// The signing secret is only ever returned here.
+	response := mapWebhookEndpointToResponse(endpoint)
+	response.Secret = secret
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleGetWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	user, isAdmin, err := cfg.webhookCaller(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	endpoints, err := cfg.Db.GetWebhookEndpoints(r.Context(), database.GetWebhookEndpointsParams{
+		UserID:  uuid.NullUUID{UUID: user.ID, Valid: true},
+		Column2: isAdmin,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error retrieving webhook endpoints", "error", err)
+		http.Error(w, "Failed to retrieve webhook endpoints", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponseWebhookEndpoint{}
+	for _, endpoint := range endpoints {
+		response = append(response, mapWebhookEndpointToResponse(endpoint))
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := cfg.webhookEndpointForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.Db.DeleteWebhookEndpoint(r.Context(), endpoint.ID)
+	if err != nil {
+		http.Error(w, "Failed to delete webhook endpoint", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleEnableWebhookEndpoint turns a disabled endpoint back on. Deliveries
+// queued before it was disabled resume from where they left off.
+func (cfg *Api) handleEnableWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := cfg.webhookEndpointForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	endpoint, err := cfg.Db.EnableWebhookEndpoint(r.Context(), endpoint.ID)
+	if err != nil {
+		http.Error(w, "Failed to enable webhook endpoint", http.StatusInternalServerError)
+		return
+	}
+
+	response := mapWebhookEndpointToResponse(endpoint)
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := cfg.webhookEndpointForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := cfg.Db.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error retrieving webhook deliveries", "error", err)
+		http.Error(w, "Failed to retrieve webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponseWebhookDelivery{}
+	for _, delivery := range deliveries {
+		response = append(response, mapWebhookDeliveryToResponse(delivery))
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (cfg *Api) handleGetWebhookDeliveryAttempts(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := cfg.webhookEndpointForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := cfg.Db.GetWebhookDeliveryByID(r.Context(), deliveryID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && delivery.EndpointID != endpoint.ID) {
+		http.Error(w, "Webhook delivery not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve webhook delivery", http.StatusInternalServerError)
+		return
+	}
+
+	attempts, err := cfg.Db.GetWebhookDeliveryAttempts(r.Context(), delivery.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error retrieving webhook delivery attempts", "error", err)
+		http.Error(w, "Failed to retrieve webhook delivery attempts", http.StatusInternalServerError)
+		return
+	}
+
+	response := []ResponseWebhookDeliveryAttempt{}
+	for _, attempt := range attempts {
+		response = append(response, mapWebhookDeliveryAttemptToResponse(attempt))
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
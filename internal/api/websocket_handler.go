@@ -0,0 +1,366 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+	"github.com/joaogiacometti/goserver/internal/stream"
+)
+
+const (
+	MaxWebSocketChannels  = 20
+	WebSocketSendBuffer   = 64
+	WebSocketReadLimit    = 4096
+	WebSocketWriteTimeout = 10 * time.Second
+)
+
+const MessageEventCreated = "message.created"
+
+var wsAcknowledgements = map[string]string{
+	"subscribe":   "subscribed",
+	"unsubscribe": "unsubscribed",
+	"auth":        "authenticated",
+}
+
+type wsClientMessage struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+type wsServerMessage struct {
+	Type    string          `json:"type"`
+	Channel string          `json:"channel,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	ID      uint64          `json:"id,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// wsSession is one authenticated WebSocket connection. Events from every
+// subscribed channel are funnelled through a bounded send queue; a client
+// that cannot keep up is disconnected rather than slowing down the hubs.
+type wsSession struct {
+	cfg    *Api
+	conn   *websocket.Conn
+	userID uuid.UUID
+	send   chan wsServerMessage
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	closeOnce sync.Once
+
+	mu       sync.Mutex
+	channels map[string]context.CancelFunc
+	expiry   *time.Timer
+}
+
+// parseSocketToken accepts first-party access tokens and reports when they
+// expire, so the connection can be closed at that moment.
+func (cfg *Api) parseSocketToken(token string) (uuid.UUID, time.Time, error) {
+	claims, err := auth.ParseAccessToken(token, cfg.JwtTokenSecret)
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+	if claims.ClientID != "" || claims.ExpiresAt == nil {
+		return uuid.Nil, time.Time{}, errors.New("token cannot be used for WebSocket connections")
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+
+	return userID, claims.ExpiresAt.Time, nil
+}
+
+// handleWebSocket upgrades the request and lets the client subscribe to
+// channels. Browsers cannot set headers on WebSocket requests, so the access
+// token may also be passed as the access_token query parameter.
+func (cfg *Api) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		token = r.URL.Query().Get("access_token")
+	}
+
+	userID, expiresAt, err := cfg.parseSocketToken(token)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// The server's read and write timeouts are armed on the connection before
+	// it is hijacked and would otherwise cut the socket off mid-session.
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error accepting websocket", "error", err)
+		return
+	}
+	conn.SetReadLimit(WebSocketReadLimit)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	session := &wsSession{
+		cfg:      cfg,
+		conn:     conn,
+		userID:   userID,
+		send:     make(chan wsServerMessage, WebSocketSendBuffer),
+		ctx:      ctx,
+		cancel:   cancel,
+		channels: map[string]context.CancelFunc{},
+	}
+	session.expiry = time.AfterFunc(time.Until(expiresAt), func() {
+		session.close(websocket.StatusPolicyViolation, "token expired")
+	})
+	defer session.expiry.Stop()
+
+	go session.writeLoop()
+	session.readLoop()
+	session.close(websocket.StatusNormalClosure, "")
+}
+
+func (s *wsSession) close(code websocket.StatusCode, reason string) {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		s.conn.Close(code, reason)
+	})
+}
+
+func (s *wsSession) writeLoop() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.cfg.ShuttingDown:
+			s.close(websocket.StatusGoingAway, "server shutting down")
+			return
+		case message := <-s.send:
+			data, err := json.Marshal(message)
+			if err != nil {
+				slog.Error("error encoding websocket message", "error", err)
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(s.ctx, WebSocketWriteTimeout)
+			err = s.conn.Write(ctx, websocket.MessageText, data)
+			cancel()
+			if err != nil {
+				s.close(websocket.StatusGoingAway, "write failed")
+				return
+			}
+		}
+	}
+}
+
+// enqueue never blocks the caller. A full queue means the client has stopped
+// reading, so the connection is closed.
+func (s *wsSession) enqueue(message wsServerMessage) {
+	select {
+	case s.send <- message:
+	default:
+		go s.close(websocket.StatusPolicyViolation, "client too slow")
+	}
+}
+
+func (s *wsSession) readLoop() {
+	for {
+		_, data, err := s.conn.Read(s.ctx)
+		if err != nil {
+			return
+		}
+
+		var message wsClientMessage
+		err = json.Unmarshal(data, &message)
+		if err != nil {
+			s.enqueue(wsServerMessage{Type: "error", Error: "invalid message"})
+			continue
+		}
+
+		switch message.Type {
+		case "subscribe":
+			err = s.subscribe(message.Channel)
+		case "unsubscribe":
+			err = s.unsubscribe(message.Channel)
+		case "auth":
+			err = s.reauthenticate(message.Token)
+		default:
+			err = errors.New("unknown message type")
+		}
+
+		if err != nil {
+			s.enqueue(wsServerMessage{Type: "error", Channel: message.Channel, Error: err.Error()})
+			continue
+		}
+		s.enqueue(wsServerMessage{Type: wsAcknowledgements[message.Type], Channel: message.Channel})
+	}
+}
+
+// reauthenticate lets a client swap in a fresh access token for the same user
+// before the current one expires.
+func (s *wsSession) reauthenticate(token string) error {
+	userID, expiresAt, err := s.cfg.parseSocketToken(token)
+	if err != nil || userID != s.userID {
+		return errors.New("invalid token")
+	}
+
+	s.expiry.Reset(time.Until(expiresAt))
+	return nil
+}
+
+// subscribe starts forwarding a channel's events. Supported channels are
+// "home" (the user and the accounts they follow), "chirps" (everything the
+// user may see), "mentions" (new chirps that mention the user),
+// "author:<id>", "conversation:<id>" and "notifications".
+func (s *wsSession) subscribe(channel string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.channels[channel]; ok {
+		return nil
+	}
+	if len(s.channels) >= MaxWebSocketChannels {
+		return fmt.Errorf("at most %d channels per connection", MaxWebSocketChannels)
+	}
+
+	hub, accepts, err := s.resolveChannel(channel)
+	if err != nil {
+		return err
+	}
+	if hub == nil {
+		return errors.New("channel is not available")
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.channels[channel] = cancel
+
+	sub, _ := hub.Subscribe(0)
+	go func() {
+		defer sub.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-sub.Events:
+				if !ok {
+					s.close(websocket.StatusPolicyViolation, "client too slow")
+					return
+				}
+				if !accepts(ctx, event) {
+					continue
+				}
+				s.enqueue(wsServerMessage{
+					Type:    "event",
+					Channel: channel,
+					Event:   event.Type,
+					ID:      event.ID,
+					Data:    event.Data,
+				})
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *wsSession) unsubscribe(channel string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cancel, ok := s.channels[channel]
+	if !ok {
+		return errors.New("not subscribed")
+	}
+	cancel()
+	delete(s.channels, channel)
+	return nil
+}
+
+func (s *wsSession) resolveChannel(channel string) (*stream.Hub, func(context.Context, stream.Event) bool, error) {
+	name, value, _ := strings.Cut(channel, ":")
+
+	switch name {
+	case "home", "chirps":
+		filter, err := s.cfg.newStreamFilter(s.ctx, s.userID, uuid.Nil, name == "home")
+		if err != nil {
+			return nil, nil, errors.New("failed to subscribe")
+		}
+		if filter.following != nil {
+			filter.following[s.userID] = true
+		}
+		return s.cfg.ChirpStream, filter.accepts, nil
+	case "mentions":
+		user, err := s.cfg.Db.GetUserByID(s.ctx, s.userID)
+		if err != nil {
+			return nil, nil, errors.New("failed to subscribe")
+		}
+		filter, err := s.cfg.newStreamFilter(s.ctx, s.userID, uuid.Nil, false)
+		if err != nil {
+			return nil, nil, errors.New("failed to subscribe")
+		}
+		accepts := func(ctx context.Context, event stream.Event) bool {
+			if event.Type != ChirpEventCreated {
+				return false
+			}
+			var chirp ResponseChrip
+			if json.Unmarshal(event.Data, &chirp) != nil {
+				return false
+			}
+			mentioned := slices.ContainsFunc(mentionedEmails(chirp.Body), func(email string) bool {
+				return strings.EqualFold(email, user.Email)
+			})
+			return mentioned && filter.accepts(ctx, event)
+		}
+		return s.cfg.ChirpStream, accepts, nil
+	case "author":
+		authorID, err := uuid.Parse(value)
+		if err != nil {
+			return nil, nil, errors.New("invalid author ID")
+		}
+		filter, err := s.cfg.newStreamFilter(s.ctx, s.userID, authorID, false)
+		if err != nil {
+			return nil, nil, errors.New("failed to subscribe")
+		}
+		return s.cfg.ChirpStream, filter.accepts, nil
+	case "conversation":
+		conversationID, err := uuid.Parse(value)
+		if err != nil {
+			return nil, nil, errors.New("invalid conversation ID")
+		}
+		isMember, err := s.cfg.Db.IsConversationMember(s.ctx, database.IsConversationMemberParams{
+			ConversationID: conversationID,
+			UserID:         s.userID,
+		})
+		if err != nil || !isMember {
+			return nil, nil, errors.New("conversation not found")
+		}
+		accepts := func(_ context.Context, event stream.Event) bool {
+			return event.Topic == conversationID
+		}
+		return s.cfg.MessageStream, accepts, nil
+	case "notifications":
+		accepts := func(_ context.Context, event stream.Event) bool {
+			return event.Topic == s.userID
+		}
+		return s.cfg.NotificationStream, accepts, nil
+	default:
+		return nil, nil, errors.New("unknown channel")
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+	"github.com/joaogiacometti/goserver/internal/database"
+	"github.com/joaogiacometti/goserver/internal/stream"
+)
+
+// socketDB adds conversation membership and email addresses to socialDB.
+type socketDB struct {
+	*socialDB
+	members map[userPair]bool
+}
+
+func (db *socketDB) IsConversationMember(ctx context.Context, arg database.IsConversationMemberParams) (bool, error) {
+	return db.members[userPair{arg.ConversationID, arg.UserID}], nil
+}
+
+// dialSocket connects to handleWebSocket with an access token for userID
+// that expires after ttl.
+func dialSocket(t *testing.T, cfg *Api, userID uuid.UUID, ttl time.Duration) *websocket.Conn {
+	t.Helper()
+
+	token, err := auth.MakeJWT(userID, testSecret, ttl)
+	if err != nil {
+		t.Fatalf("MakeJWT() error = %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(cfg.handleWebSocket))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"?access_token="+token, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+	return conn
+}
+
+func socketRoundTrip(t *testing.T, conn *websocket.Conn, message wsClientMessage) wsServerMessage {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	data, _ := json.Marshal(message)
+	err := conn.Write(ctx, websocket.MessageText, data)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	_, data, err = conn.Read(ctx)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	var response wsServerMessage
+	err = json.Unmarshal(data, &response)
+	if err != nil {
+		t.Fatalf("invalid message: %v", err)
+	}
+	return response
+}
+
+func newSocketAPI() (*Api, *socketDB) {
+	db := &socketDB{socialDB: newSocialDB(), members: map[userPair]bool{}}
+	return &Api{
+		Db:                 db,
+		JwtTokenSecret:     testSecret,
+		ChirpStream:        stream.NewHub(10, 8),
+		MessageStream:      stream.NewHub(10, 8),
+		NotificationStream: stream.NewHub(10, 8),
+	}, db
+}
+
+func TestWebSocketConversationMembership(t *testing.T) {
+	cfg, db := newSocketAPI()
+	userID := db.addUser(false)
+	memberOf := uuid.New()
+	db.members[userPair{memberOf, userID}] = true
+
+	conn := dialSocket(t, cfg, userID, time.Hour)
+
+	tests := []struct {
+		name           string
+		conversationID uuid.UUID
+		wantType       string
+	}{
+		{name: "Member", conversationID: memberOf, wantType: "subscribed"},
+		{name: "Not a member", conversationID: uuid.New(), wantType: "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := socketRoundTrip(t, conn, wsClientMessage{Type: "subscribe", Channel: "conversation:" + tt.conversationID.String()})
+			if response.Type != tt.wantType {
+				t.Errorf("response = %+v, want type %s", response, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestWebSocketMentionsChannel(t *testing.T) {
+	cfg, db := newSocketAPI()
+	userID := db.addUser(false)
+	user := db.users[userID]
+	user.Email = "walt@example.com"
+	db.users[userID] = user
+	authorID := db.addUser(false)
+
+	conn := dialSocket(t, cfg, userID, time.Hour)
+	if response := socketRoundTrip(t, conn, wsClientMessage{Type: "subscribe", Channel: "mentions"}); response.Type != "subscribed" {
+		t.Fatalf("response = %+v, want subscribed", response)
+	}
+
+	for _, body := range []string{"nothing to see here", "thanks @Walt@example.com!"} {
+		data, _ := json.Marshal(MapChirpToResponse(database.Chirp{ID: uuid.New(), Body: body, UserID: authorID}))
+		cfg.ChirpStream.Publish(stream.Event{Type: ChirpEventCreated, UserID: authorID, Data: data})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, data, err := conn.Read(ctx)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	var message wsServerMessage
+	json.Unmarshal(data, &message)
+	var chirp ResponseChrip
+	json.Unmarshal(message.Data, &chirp)
+	if message.Channel != "mentions" || chirp.Body != "thanks @Walt@example.com!" {
+		t.Errorf("got %s on %s, want the mentioning chirp", chirp.Body, message.Channel)
+	}
+}
+
+func TestWebSocketClosesOnTokenExpiry(t *testing.T) {
+	cfg, db := newSocketAPI()
+	conn := dialSocket(t, cfg, db.addUser(false), 2*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _, err := conn.Read(ctx)
+	if status := websocket.CloseStatus(err); status != websocket.StatusPolicyViolation {
+		t.Errorf("close status = %v (%v), want %v", status, err, websocket.StatusPolicyViolation)
+	}
+}
+
+// A client whose send queue is full is disconnected instead of blocking the
+// publisher.
+func TestWebSocketClosesSlowClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		ctx, cancel := context.WithCancel(r.Context())
+		session := &wsSession{conn: conn, send: make(chan wsServerMessage, 1), ctx: ctx, cancel: cancel}
+
+		// Nothing drains the queue, as when the writer is stuck on a client
+		// that stopped reading.
+		session.enqueue(wsServerMessage{Type: "event"})
+		session.enqueue(wsServerMessage{Type: "event"})
+		<-ctx.Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.CloseNow()
+
+	_, _, err = conn.Read(ctx)
+	if status := websocket.CloseStatus(err); status != websocket.StatusPolicyViolation {
+		t.Errorf("close status = %v (%v), want %v", status, err, websocket.StatusPolicyViolation)
+	}
+}
@@ -18,9 +18,14 @@ type TokenType string
 
 const (
 	TokenTypeAccess TokenType = "chirpy-access"
+	TokenTypeMFA    TokenType = "chirpy-mfa"
 )
 
-var DefaultDuration = time.Hour
+// DefaultDuration is how long access tokens last unless the caller asks for
+// another lifetime.
+const DefaultDuration = time.Hour
+
+var MFAChallengeDuration = time.Minute * 5
 
 func HashPassword(password string) (string, error) {
 	dat, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -37,46 +42,108 @@ func CheckPasswordHash(password, hash string) error {
 func MakeJWT(
 	userID uuid.UUID,
 	tokenSecret string,
+	expiresIn time.Duration,
+) (string, error) {
+	return makeToken(userID, tokenSecret, TokenTypeAccess, expiresIn, Claims{})
+}
+
+// ValidateJWT accepts first-party access tokens only. Tokens issued to OAuth
+// clients are scoped and must go through ValidateJWTWithScope.
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	claims, err := parseToken(tokenString, tokenSecret, TokenTypeAccess)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if claims.ClientID != "" {
+		return uuid.Nil, errors.New("token was issued to an OAuth client")
+	}
+
+	return claims.UserID()
+}
+
+// AccessTokenExpiry reports when a valid first-party access token stops
+// being accepted.
+func AccessTokenExpiry(tokenString, tokenSecret string) (time.Time, error) {
+	claims, err := parseToken(tokenString, tokenSecret, TokenTypeAccess)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if claims.ExpiresAt == nil {
+		return time.Time{}, errors.New("token has no expiry")
+	}
+
+	return claims.ExpiresAt.Time, nil
+}
+
+// MakeMFAToken issues the short-lived challenge token handed out after a
+// correct password when the user still has to pass a second factor. The
+// challenge ID is carried as the token ID so the server can track attempts
+// and make the token single-use.
+func MakeMFAToken(userID, challengeID uuid.UUID, tokenSecret string) (string, error) {
+	claims := Claims{}
+	claims.ID = challengeID.String()
+	return makeToken(userID, tokenSecret, TokenTypeMFA, MFAChallengeDuration, claims)
+}
+
+// ValidateMFAToken returns the user and challenge an MFA token was issued for.
+func ValidateMFAToken(tokenString, tokenSecret string) (uuid.UUID, uuid.UUID, error) {
+	claims, err := parseToken(tokenString, tokenSecret, TokenTypeMFA)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	challengeID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, errors.New("invalid challenge ID")
+	}
+
+	return userID, challengeID, nil
+}
+
+func makeToken(
+	userID uuid.UUID,
+	tokenSecret string,
+	tokenType TokenType,
+	duration time.Duration,
+	claims Claims,
 ) (string, error) {
 	signingKey := []byte(tokenSecret)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    string(tokenType),
 		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(DefaultDuration)),
+		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(duration)),
 		Subject:   userID.String(),
-	})
+		ID:        claims.ID,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(signingKey)
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
+func parseToken(tokenString, tokenSecret string, tokenType TokenType) (Claims, error) {
+	claimsStruct := Claims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&claimsStruct,
 		func(token *jwt.Token) (any, error) { return []byte(tokenSecret), nil },
 	)
 	if err != nil {
-		return uuid.Nil, err
-	}
-
-	userIDString, err := token.Claims.GetSubject()
-	if err != nil {
-		return uuid.Nil, err
+		return Claims{}, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return Claims{}, err
 	}
-	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+	if issuer != string(tokenType) {
+		return Claims{}, errors.New("invalid issuer")
 	}
 
-	id, err := uuid.Parse(userIDString)
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
-	}
-	return id, nil
+	return claimsStruct, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -85,16 +152,12 @@ func GetBearerToken(headers http.Header) (string, error) {
 		return "", errors.New("no token found")
 	}
 
-	return strings.Fields(token)[1], nil
-}
-
-func GetApiKey(headers http.Header) (string, error) {
-	token := headers.Get("Authorization")
-	if token == "" {
-		return "", errors.New("no API key found")
+	fields := strings.Fields(token)
+	if len(fields) != 2 {
+		return "", errors.New("malformed authorization header")
 	}
 
-	return strings.Fields(token)[1], nil
+	return fields[1], nil
 }
 
 func MakeRefreshToken() (string, error) {
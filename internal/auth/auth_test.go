@@ -1,7 +1,9 @@
 package auth
 
 import (
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -62,7 +64,7 @@ func TestCheckPasswordHash(t *testing.T) {
 
 func TestValidateJWT(t *testing.T) {
 	userID := uuid.New()
-	validToken, _ := MakeJWT(userID, "secret")
+	validToken, _ := MakeJWT(userID, "secret", DefaultDuration)
 
 	tests := []struct {
 		name        string
@@ -107,3 +109,82 @@ func TestValidateJWT(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateMFAToken(t *testing.T) {
+	userID := uuid.New()
+	challengeID := uuid.New()
+	token, _ := MakeMFAToken(userID, challengeID, "secret")
+
+	gotUserID, gotChallengeID, err := ValidateMFAToken(token, "secret")
+	if err != nil {
+		t.Fatalf("ValidateMFAToken() error = %v", err)
+	}
+	if gotUserID != userID || gotChallengeID != challengeID {
+		t.Errorf("ValidateMFAToken() = %v, %v, want %v, %v", gotUserID, gotChallengeID, userID, challengeID)
+	}
+
+	accessToken, _ := MakeJWT(userID, "secret", DefaultDuration)
+	if _, _, err := ValidateMFAToken(accessToken, "secret"); err == nil {
+		t.Error("ValidateMFAToken() accepted an access token")
+	}
+}
+
+func TestAccessTokenExpiry(t *testing.T) {
+	token, _ := MakeJWT(uuid.New(), "secret", time.Hour)
+
+	expiresAt, err := AccessTokenExpiry(token, "secret")
+	if err != nil {
+		t.Fatalf("AccessTokenExpiry() error = %v", err)
+	}
+	if until := time.Until(expiresAt); until <= 59*time.Minute || until > time.Hour {
+		t.Errorf("AccessTokenExpiry() = %v, want about an hour from now", expiresAt)
+	}
+
+	if _, err := AccessTokenExpiry(token, "wrong"); err == nil {
+		t.Error("AccessTokenExpiry() accepted a token signed with another secret")
+	}
+}
+
+func TestGetBearerToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		wantToken string
+		wantErr   bool
+	}{
+		{
+			name:      "Valid header",
+			header:    "Bearer abc123",
+			wantToken: "abc123",
+			wantErr:   false,
+		},
+		{
+			name:    "Missing header",
+			header:  "",
+			wantErr: true,
+		},
+		{
+			name:    "Scheme without token",
+			header:  "Bearer",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			if tt.header != "" {
+				headers.Set("Authorization", tt.header)
+			}
+
+			gotToken, err := GetBearerToken(headers)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetBearerToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotToken != tt.wantToken {
+				t.Errorf("GetBearerToken() gotToken = %v, want %v", gotToken, tt.wantToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestValidateJWTWithScope(t *testing.T) {
+	userID := uuid.New()
+	firstPartyToken, _ := MakeJWT(userID, "secret", DefaultDuration)
+	scopedToken, _ := MakeScopedJWT(userID, "secret", uuid.New(), ScopeProfile, DefaultDuration)
+
+	tests := []struct {
+		name        string
+		tokenString string
+		scope       string
+		wantErr     bool
+	}{
+		{
+			name:        "First-party token has every scope",
+			tokenString: firstPartyToken,
+			scope:       ScopeChirpsWrite,
+			wantErr:     false,
+		},
+		{
+			name:        "Scoped token with granted scope",
+			tokenString: scopedToken,
+			scope:       ScopeProfile,
+			wantErr:     false,
+		},
+		{
+			name:        "Scoped token without granted scope",
+			tokenString: scopedToken,
+			scope:       ScopeChirpsWrite,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID, err := ValidateJWTWithScope(tt.tokenString, "secret", tt.scope)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJWTWithScope() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && gotUserID != userID {
+				t.Errorf("ValidateJWTWithScope() gotUserID = %v, want %v", gotUserID, userID)
+			}
+		})
+	}
+
+	if _, err := ValidateJWT(scopedToken, "secret"); err == nil {
+		t.Errorf("ValidateJWT() accepted a token issued to an OAuth client")
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	// RFC 7636 appendix B example.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if !VerifyPKCE(verifier, challenge) {
+		t.Errorf("VerifyPKCE() rejected the RFC 7636 example")
+	}
+	if VerifyPKCE("wrong", challenge) {
+		t.Errorf("VerifyPKCE() accepted a wrong verifier")
+	}
+}
+
+func TestNormalizeScope(t *testing.T) {
+	got, err := NormalizeScope("profile chirps:write profile")
+	if err != nil || got != "chirps:write profile" {
+		t.Errorf("NormalizeScope() = %q, %v", got, err)
+	}
+	if _, err := NormalizeScope("admin"); err == nil {
+		t.Errorf("NormalizeScope() accepted an unknown scope")
+	}
+	if _, err := NormalizeScope(""); err == nil {
+		t.Errorf("NormalizeScope() accepted an empty scope")
+	}
+}
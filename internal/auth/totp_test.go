@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	// RFC 6238 test secret, truncated to six digits.
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		name   string
+		code   string
+		secret string
+		time   time.Time
+		want   bool
+	}{
+		{
+			name:   "RFC vector at 59s",
+			code:   "287082",
+			secret: secret,
+			time:   time.Unix(59, 0),
+			want:   true,
+		},
+		{
+			name:   "RFC vector at 1111111109s",
+			code:   "081804",
+			secret: secret,
+			time:   time.Unix(1111111109, 0),
+			want:   true,
+		},
+		{
+			name:   "Previous period is accepted",
+			code:   "287082",
+			secret: secret,
+			time:   time.Unix(89, 0),
+			want:   true,
+		},
+		{
+			name:   "Code too old",
+			code:   "287082",
+			secret: secret,
+			time:   time.Unix(120, 0),
+			want:   false,
+		},
+		{
+			name:   "Wrong code",
+			code:   "000000",
+			secret: secret,
+			time:   time.Unix(59, 0),
+			want:   false,
+		},
+		{
+			name:   "Invalid secret",
+			code:   "287082",
+			secret: "not base32!",
+			time:   time.Unix(59, 0),
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateTOTP(tt.code, tt.secret, tt.time); got != tt.want {
+				t.Errorf("ValidateTOTP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchTOTPStep(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		name     string
+		time     time.Time
+		wantStep int64
+	}{
+		{name: "Current period", time: time.Unix(59, 0), wantStep: 1},
+		{name: "Previous period", time: time.Unix(89, 0), wantStep: 1},
+		{name: "Next period", time: time.Unix(29, 0), wantStep: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := MatchTOTP("287082", secret, tt.time)
+			if !ok {
+				t.Fatal("MatchTOTP() rejected a valid code")
+			}
+			if step != tt.wantStep {
+				t.Errorf("MatchTOTP() step = %d, want %d", step, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	codes, err := MakeRecoveryCodes(2)
+	if err != nil {
+		t.Fatalf("MakeRecoveryCodes() error = %v", err)
+	}
+	if codes[0] == codes[1] {
+		t.Errorf("MakeRecoveryCodes() returned duplicate codes")
+	}
+	if HashRecoveryCode(codes[0]) != HashRecoveryCode(" "+codes[0]+" ") {
+		t.Errorf("HashRecoveryCode() should ignore surrounding whitespace")
+	}
+	if HashRecoveryCode(codes[0]) == HashRecoveryCode(codes[1]) {
+		t.Errorf("HashRecoveryCode() collided for different codes")
+	}
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookTolerance is how far a webhook timestamp may drift from our clock.
+// Older deliveries are rejected, which bounds how long event IDs must be kept
+// to detect replays.
+var WebhookTolerance = time.Minute * 5
+
+var (
+	ErrWebhookSignature = errors.New("invalid webhook signature")
+	ErrWebhookStale     = errors.New("webhook timestamp outside tolerance")
+)
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>". Binding
+// the timestamp into the signature stops it being swapped on a replay.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks a "sha256=<hex>" signature header and a unix-seconds
+// timestamp header against the raw request body.
+func VerifyWebhook(secret, signature, timestamp string, body []byte, now time.Time) error {
+	signature, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return ErrWebhookSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrWebhookStale
+	}
+
+	expected := SignWebhook(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrWebhookSignature
+	}
+
+	drift := now.Sub(time.Unix(seconds, 0))
+	if drift > WebhookTolerance || drift < -WebhookTolerance {
+		return ErrWebhookStale
+	}
+
+	return nil
+}
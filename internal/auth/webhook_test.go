@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	staleTimestamp := strconv.FormatInt(now.Add(-WebhookTolerance-time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		signature string
+		timestamp string
+		body      []byte
+		wantErr   error
+	}{
+		{
+			name:      "Valid signature",
+			signature: "sha256=" + SignWebhook("secret", timestamp, body),
+			timestamp: timestamp,
+			body:      body,
+			wantErr:   nil,
+		},
+		{
+			name:      "Wrong secret",
+			signature: "sha256=" + SignWebhook("other", timestamp, body),
+			timestamp: timestamp,
+			body:      body,
+			wantErr:   ErrWebhookSignature,
+		},
+		{
+			name:      "Tampered body",
+			signature: "sha256=" + SignWebhook("secret", timestamp, body),
+			timestamp: timestamp,
+			body:      []byte(`{"id":"evt_1","event":"user.downgraded"}`),
+			wantErr:   ErrWebhookSignature,
+		},
+		{
+			name:      "Missing prefix",
+			signature: SignWebhook("secret", timestamp, body),
+			timestamp: timestamp,
+			body:      body,
+			wantErr:   ErrWebhookSignature,
+		},
+		{
+			name:      "Timestamp swapped after signing",
+			signature: "sha256=" + SignWebhook("secret", staleTimestamp, body),
+			timestamp: timestamp,
+			body:      body,
+			wantErr:   ErrWebhookSignature,
+		},
+		{
+			name:      "Stale delivery",
+			signature: "sha256=" + SignWebhook("secret", staleTimestamp, body),
+			timestamp: staleTimestamp,
+			body:      body,
+			wantErr:   ErrWebhookStale,
+		},
+		{
+			name:      "Malformed timestamp",
+			signature: "sha256=" + SignWebhook("secret", "soon", body),
+			timestamp: "soon",
+			body:      body,
+			wantErr:   ErrWebhookStale,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhook("secret", tt.signature, tt.timestamp, tt.body, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyWebhook() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is everything the server reads at startup. Each setting can come
+// from, in increasing order of precedence: its default, the config file, the
+// .env file and the process environment. The env tag names the variable.
+// Fields tagged secret are redacted when the config is logged.
+type Config struct {
+	Platform string   `toml:"platform" yaml:"platform" env:"PLATFORM"`
+	Server   Server   `toml:"server" yaml:"server"`
+	Database Database `toml:"database" yaml:"database"`
+	Auth     Auth     `toml:"auth" yaml:"auth"`
+	WebAuthn WebAuthn `toml:"webauthn" yaml:"webauthn"`
+	OIDC     []OIDC   `toml:"oidc" yaml:"oidc"`
+	Log      Log      `toml:"log" yaml:"log"`
+	Tracing  Tracing  `toml:"tracing" yaml:"tracing"`
+	Features Features `toml:"features" yaml:"features"`
+}
+
+type Server struct {
+	Addr              string        `toml:"addr" yaml:"addr" env:"HTTP_ADDR"`
+	TLSCertFile       string        `toml:"tls_cert_file" yaml:"tls_cert_file" env:"TLS_CERT_FILE"`
+	TLSKeyFile        string        `toml:"tls_key_file" yaml:"tls_key_file" env:"TLS_KEY_FILE"`
+	ReadTimeout       time.Duration `toml:"read_timeout" yaml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `toml:"read_header_timeout" yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `toml:"write_timeout" yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `toml:"idle_timeout" yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownDelay     time.Duration `toml:"shutdown_delay" yaml:"shutdown_delay" env:"SHUTDOWN_DELAY"`
+	ShutdownTimeout   time.Duration `toml:"shutdown_timeout" yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	MaxHeaderBytes    int           `toml:"max_header_bytes" yaml:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES"`
+	MaxBodyBytes      int64         `toml:"max_body_bytes" yaml:"max_body_bytes" env:"HTTP_MAX_BODY_BYTES"`
+	MetricsToken      string        `toml:"metrics_token" yaml:"metrics_token" env:"METRICS_TOKEN" secret:"true"`
+}
+
+// TLS reports whether the server should listen with HTTPS.
+func (s Server) TLS() bool {
+	return s.TLSCertFile != ""
+}
+
+type Database struct {
+	URL             string        `toml:"url" yaml:"url" env:"DB_URL" secret:"true"`
+	MaxOpenConns    int           `toml:"max_open_conns" yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `toml:"max_idle_conns" yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `toml:"conn_max_lifetime" yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `toml:"conn_max_idle_time" yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+}
+
+type Auth struct {
+	JWTSecret       string        `toml:"jwt_secret" yaml:"jwt_secret" env:"JWT_TOKEN_SECRET" secret:"true"`
+	PolkaKey        string        `toml:"polka_key" yaml:"polka_key" env:"POLKA_KEY" secret:"true"`
+	AccessTokenTTL  time.Duration `toml:"access_token_ttl" yaml:"access_token_ttl" env:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL time.Duration `toml:"refresh_token_ttl" yaml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL"`
+	AdminEmails     []string      `toml:"admin_emails" yaml:"admin_emails" env:"ADMIN_EMAILS"`
+}
+
+// WebAuthn enables passkeys when RPID is set.
+type WebAuthn struct {
+	RPID      string   `toml:"rp_id" yaml:"rp_id" env:"WEBAUTHN_RP_ID"`
+	RPOrigins []string `toml:"rp_origins" yaml:"rp_origins" env:"WEBAUTHN_RP_ORIGINS"`
+}
+
+// OIDC is one external identity provider. In the environment, OIDC_PROVIDERS
+// lists the names and each provider reads OIDC_<NAME>_ISSUER, _CLIENT_ID,
+// _CLIENT_SECRET and _REDIRECT_URL.
+type OIDC struct {
+	Name         string `toml:"name" yaml:"name"`
+	IssuerURL    string `toml:"issuer" yaml:"issuer"`
+	ClientID     string `toml:"client_id" yaml:"client_id"`
+	ClientSecret string `toml:"client_secret" yaml:"client_secret" secret:"true"`
+	RedirectURL  string `toml:"redirect_url" yaml:"redirect_url"`
+}
+
+type Log struct {
+	Level  string `toml:"level" yaml:"level" env:"LOG_LEVEL"`
+	Format string `toml:"format" yaml:"format" env:"LOG_FORMAT"`
+}
+
+type Tracing struct {
+	Exporter    string `toml:"exporter" yaml:"exporter" env:"OTEL_TRACES_EXPORTER"`
+	ServiceName string `toml:"service_name" yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+}
+
+// Features switch optional behaviour off without a deploy.
+type Features struct {
+	Signups          bool `toml:"signups" yaml:"signups" env:"FEATURE_SIGNUPS"`
+	OutgoingWebhooks bool `toml:"outgoing_webhooks" yaml:"outgoing_webhooks" env:"FEATURE_OUTGOING_WEBHOOKS"`
+}
+
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:              ":8080",
+			ReadTimeout:       time.Second * 15,
+			ReadHeaderTimeout: time.Second * 5,
+			WriteTimeout:      time.Second * 30,
+			IdleTimeout:       time.Second * 120,
+			ShutdownTimeout:   time.Second * 30,
+			MaxHeaderBytes:    1 << 20,
+			MaxBodyBytes:      1 << 20,
+		},
+		Database: Database{
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: time.Minute * 30,
+			ConnMaxIdleTime: time.Minute * 5,
+		},
+		Auth: Auth{
+			AccessTokenTTL:  time.Hour,
+			RefreshTokenTTL: time.Hour * 24 * 60,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "text",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			ServiceName: "chirpy",
+		},
+		Features: Features{
+			Signups:          true,
+			OutgoingWebhooks: true,
+		},
+	}
+}
+
+// Load reads .env into the environment, then builds the config from the
+// defaults, the file at path, and the environment. An empty path falls back to
+// CONFIG_FILE, and with neither no file is read. The file format follows its
+// extension: .toml, .yaml or .yml.
+func Load(path string) (*Config, error) {
+	err := godotenv.Load()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("cannot read .env: %w", err)
+	}
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	cfg := Default()
+	if path != "" {
+		err := cfg.readFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = cfg.readEnv(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		_, err = toml.Decode(string(data), c)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("config file %s must be .toml, .yaml or .yml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// readEnv overrides every field whose variable is set to a non-empty value,
+// so an empty variable keeps the default. Lists are comma separated.
+func (c *Config) readEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	setFromEnv(reflect.ValueOf(c).Elem(), lookup, &errs)
+
+	if names, ok := lookup("OIDC_PROVIDERS"); ok {
+		c.OIDC = nil
+		for _, name := range splitList(names) {
+			prefix := "OIDC_" + strings.ToUpper(name) + "_"
+			issuer, _ := lookup(prefix + "ISSUER")
+			clientID, _ := lookup(prefix + "CLIENT_ID")
+			clientSecret, _ := lookup(prefix + "CLIENT_SECRET")
+			redirectURL, _ := lookup(prefix + "REDIRECT_URL")
+
+			c.OIDC = append(c.OIDC, OIDC{
+				Name:         name,
+				IssuerURL:    issuer,
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				RedirectURL:  redirectURL,
+			})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func setFromEnv(v reflect.Value, lookup func(string) (string, bool), errs *[]error) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		tag := v.Type().Field(i)
+
+		if field.Kind() == reflect.Struct {
+			setFromEnv(field, lookup, errs)
+			continue
+		}
+
+		name := tag.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		value, ok := lookup(name)
+		if !ok || value == "" {
+			continue
+		}
+
+		err := setValue(field, value)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+}
+
+func setValue(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case []string:
+		field.Set(reflect.ValueOf(splitList(value)))
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		field.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s", value)
+		}
+		field.SetInt(int64(d))
+	case int, int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		field.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate reports every invalid setting at once rather than stopping at the
+// first.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Platform != "", "PLATFORM must be set")
+	check(c.Database.URL != "", "DB_URL must be set")
+	check(c.Auth.JWTSecret != "", "JWT_TOKEN_SECRET must be set")
+	check(c.Auth.PolkaKey != "", "POLKA_KEY must be set")
+
+	_, _, err := net.SplitHostPort(c.Server.Addr)
+	check(err == nil, "HTTP_ADDR %q must be host:port, such as :8080", c.Server.Addr)
+	check((c.Server.TLSCertFile == "") == (c.Server.TLSKeyFile == ""),
+		"TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	for _, file := range []string{c.Server.TLSCertFile, c.Server.TLSKeyFile} {
+		if file != "" {
+			_, err := os.Stat(file)
+			check(err == nil, "cannot read TLS file %s", file)
+		}
+	}
+	check(c.Server.ReadTimeout >= 0, "HTTP_READ_TIMEOUT must not be negative")
+	check(c.Server.ReadHeaderTimeout > 0, "HTTP_READ_HEADER_TIMEOUT must be positive")
+	check(c.Server.WriteTimeout >= 0, "HTTP_WRITE_TIMEOUT must not be negative")
+	check(c.Server.IdleTimeout >= 0, "HTTP_IDLE_TIMEOUT must not be negative")
+	check(c.Server.ShutdownDelay >= 0, "SHUTDOWN_DELAY must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT must be positive")
+	check(c.Server.MaxHeaderBytes > 0, "HTTP_MAX_HEADER_BYTES must be positive")
+	check(c.Server.MaxBodyBytes >= 0, "HTTP_MAX_BODY_BYTES must not be negative")
+
+	check(c.Database.MaxOpenConns >= 0, "DB_MAX_OPEN_CONNS must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "DB_MAX_IDLE_CONNS must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS")
+	check(c.Database.ConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME must not be negative")
+
+	check(c.Auth.AccessTokenTTL > 0, "ACCESS_TOKEN_TTL must be positive")
+	check(c.Auth.RefreshTokenTTL > c.Auth.AccessTokenTTL, "REFRESH_TOKEN_TTL must be longer than ACCESS_TOKEN_TTL")
+
+	check(c.WebAuthn.RPID == "" || len(c.WebAuthn.RPOrigins) > 0,
+		"WEBAUTHN_RP_ORIGINS must be set when WEBAUTHN_RP_ID is")
+
+	for _, provider := range c.OIDC {
+		check(provider.Name != "", "every identity provider needs a name")
+		check(provider.IssuerURL != "" && provider.ClientID != "" && provider.RedirectURL != "",
+			"identity provider %q needs an issuer, client ID and redirect URL", provider.Name)
+	}
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "LOG_LEVEL %q must be debug, info, warn or error", c.Log.Level)
+	format := strings.ToLower(c.Log.Format)
+	check(format == "text" || format == "json", "LOG_FORMAT %q must be text or json", c.Log.Format)
+
+	switch strings.ToLower(c.Tracing.Exporter) {
+	case "", "none", "stdout", "console", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("OTEL_TRACES_EXPORTER %q must be none, stdout or otlp", c.Tracing.Exporter))
+	}
+	check(c.Tracing.ServiceName != "", "OTEL_SERVICE_NAME must not be empty")
+
+	return errors.Join(errs...)
+}
+
+// LogValue presents the config as nested groups with secrets redacted, so the
+// effective settings can be logged at startup.
+func (c *Config) LogValue() slog.Value {
+	return groupValue(reflect.ValueOf(*c))
+}
+
+func groupValue(v reflect.Value) slog.Value {
+	var attrs []slog.Attr
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		tag := v.Type().Field(i)
+		key, _, _ := strings.Cut(tag.Tag.Get("toml"), ",")
+
+		switch {
+		case tag.Tag.Get("secret") == "true":
+			attrs = append(attrs, slog.String(key, redact(field.String())))
+		case field.Kind() == reflect.Struct:
+			attrs = append(attrs, slog.Attr{Key: key, Value: groupValue(field)})
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct:
+			var items []slog.Attr
+			for j := 0; j < field.Len(); j++ {
+				items = append(items, slog.Attr{Key: strconv.Itoa(j), Value: groupValue(field.Index(j))})
+			}
+			attrs = append(attrs, slog.Attr{Key: key, Value: slog.GroupValue(items...)})
+		case field.Type() == reflect.TypeOf(time.Duration(0)):
+			attrs = append(attrs, slog.String(key, time.Duration(field.Int()).String()))
+		default:
+			attrs = append(attrs, slog.Any(key, field.Interface()))
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "[redacted]"
+}
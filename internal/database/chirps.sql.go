@@ -14,7 +14,7 @@ import (
 const createChrip = `-- name: CreateChrip :one
 INSERT INTO chirps (id, body, created_at, updated_at, user_id)
 VALUES (gen_random_uuid(), $1, NOW(), NOW(), $2)
-RETURNING id, body, created_at, updated_at, user_id
+RETURNING id, body, created_at, updated_at, user_id, hidden_at
 `
 
 type CreateChripParams struct {
@@ -31,6 +31,7 @@ func (q *Queries) CreateChrip(ctx context.Context, arg CreateChripParams) (Chirp
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
+		&i.HiddenAt,
 	)
 	return i, err
 }
@@ -46,14 +47,45 @@ func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
 }
 
 const getAllAsc = `-- name: GetAllAsc :many
-SELECT id, body, created_at, updated_at, user_id
+SELECT id, body, created_at, updated_at, user_id, hidden_at
 FROM chirps
-WHERE $1::uuid = '00000000-0000-0000-0000-000000000000' OR user_id = $1
+WHERE ($1::uuid = '00000000-0000-0000-0000-000000000000' OR user_id = $1)
+AND hidden_at IS NULL
+AND EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.status != 'banned'
+    AND users.delete_after IS NULL
+    AND (
+        NOT users.is_private
+        OR users.id = $2::uuid
+        OR EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $2::uuid
+            AND follows.followee_id = users.id
+            AND follows.status = 'accepted'
+        )
+    )
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = chirps.user_id AND blocked_id = $2::uuid)
+    OR (blocker_id = $2::uuid AND blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE muter_id = $2::uuid AND muted_id = chirps.user_id
+)
 ORDER BY created_at asc
 `
 
-func (q *Queries) GetAllAsc(ctx context.Context, dollar_1 uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllAsc, dollar_1)
+type GetAllAscParams struct {
+	Column1 uuid.UUID
+	Column2 uuid.UUID
+}
+
+func (q *Queries) GetAllAsc(ctx context.Context, arg GetAllAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllAsc, arg.Column1, arg.Column2)
 	if err != nil {
 		return nil, err
 	}
@@ -67,6 +99,7 @@ func (q *Queries) GetAllAsc(ctx context.Context, dollar_1 uuid.UUID) ([]Chirp, e
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.UserID,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -82,14 +115,45 @@ func (q *Queries) GetAllAsc(ctx context.Context, dollar_1 uuid.UUID) ([]Chirp, e
 }
 
 const getAllDesc = `-- name: GetAllDesc :many
-SELECT id, body, created_at, updated_at, user_id
+SELECT id, body, created_at, updated_at, user_id, hidden_at
 FROM chirps
-WHERE $1::uuid = '00000000-0000-0000-0000-000000000000' OR user_id = $1
+WHERE ($1::uuid = '00000000-0000-0000-0000-000000000000' OR user_id = $1)
+AND hidden_at IS NULL
+AND EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.status != 'banned'
+    AND users.delete_after IS NULL
+    AND (
+        NOT users.is_private
+        OR users.id = $2::uuid
+        OR EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $2::uuid
+            AND follows.followee_id = users.id
+            AND follows.status = 'accepted'
+        )
+    )
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = chirps.user_id AND blocked_id = $2::uuid)
+    OR (blocker_id = $2::uuid AND blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE muter_id = $2::uuid AND muted_id = chirps.user_id
+)
 ORDER BY created_at desc
 `
 
-func (q *Queries) GetAllDesc(ctx context.Context, dollar_1 uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllDesc, dollar_1)
+type GetAllDescParams struct {
+	Column1 uuid.UUID
+	Column2 uuid.UUID
+}
+
+func (q *Queries) GetAllDesc(ctx context.Context, arg GetAllDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllDesc, arg.Column1, arg.Column2)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +167,7 @@ func (q *Queries) GetAllDesc(ctx context.Context, dollar_1 uuid.UUID) ([]Chirp,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.UserID,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -118,7 +183,7 @@ func (q *Queries) GetAllDesc(ctx context.Context, dollar_1 uuid.UUID) ([]Chirp,
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, body, created_at, updated_at, user_id
+SELECT id, body, created_at, updated_at, user_id, hidden_at
 FROM chirps
 WHERE id = $1
 `
@@ -132,6 +197,55 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
+		&i.HiddenAt,
 	)
 	return i, err
 }
+
+const getChirpsByUserID = `-- name: GetChirpsByUserID :many
+SELECT id, body, created_at, updated_at, user_id, hidden_at
+FROM chirps
+WHERE user_id = $1
+ORDER BY created_at asc
+`
+
+func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
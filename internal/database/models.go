@@ -6,6 +6,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,6 +18,116 @@ type Chirp struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	UserID    uuid.UUID
+	HiddenAt  sql.NullTime
+}
+
+type ChirpReport struct {
+	ID             uuid.UUID
+	ChirpID        uuid.NullUUID
+	ChirpAuthorID  uuid.UUID
+	ChirpBody      string
+	ReporterID     uuid.UUID
+	Reason         string
+	Status         string
+	Resolution     sql.NullString
+	ResolutionNote sql.NullString
+	ResolvedBy     uuid.NullUUID
+	ResolvedAt     sql.NullTime
+	CreatedAt      time.Time
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DirectKey sql.NullString
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	LastReadAt     sql.NullTime
+	JoinedAt       time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	Status     string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type Message struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	CreatedAt      time.Time
+}
+
+type MfaChallenge struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	FailedAttempts int32
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+	UsedAt         sql.NullTime
+}
+
+type MfaFailure struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Source    string
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ActorID   uuid.NullUUID
+	Type      string
+	SubjectID uuid.NullUUID
+	ReadAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scope         string
+	CodeChallenge string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	OwnerID      uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type OidcLoginState struct {
+	State        string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
 }
 
 type RefreshToken struct {
@@ -26,13 +137,128 @@ type RefreshToken struct {
 	UserID    uuid.UUID
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
+	ClientID  uuid.NullUUID
+	Scope     sql.NullString
 }
 
 type User struct {
+	ID                      uuid.UUID
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+	Email                   string
+	HashedPassword          string
+	IsChirpyRed             bool
+	DeleteAfter             sql.NullTime
+	TotpSecret              sql.NullString
+	TotpEnabled             bool
+	Role                    string
+	Status                  string
+	StatusReason            sql.NullString
+	StatusExpiresAt         sql.NullTime
+	IsPrivate               bool
+	NotificationPreferences json.RawMessage
+	RedStatus               string
+	RedPeriodStart          sql.NullTime
+	RedPeriodEnd            sql.NullTime
+	RedGraceUntil           sql.NullTime
+	TotpLastStep            int64
+}
+
+type UserAuditLog struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ActorID   uuid.NullUUID
+	Action    string
+	Reason    sql.NullString
+	ExpiresAt sql.NullTime
+	CreatedAt time.Time
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserIdentity struct {
+	Provider  string
+	Subject   string
+	UserID    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type UserMute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type WebauthnCredential struct {
+	ID         []byte
+	UserID     uuid.UUID
+	Credential json.RawMessage
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	LastUsedAt sql.NullTime
+}
+
+type WebauthnSession struct {
+	ID        uuid.UUID
+	Data      json.RawMessage
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type WebhookDelivery struct {
 	ID             uuid.UUID
+	EndpointID     uuid.UUID
+	Event          string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+}
+
+type WebhookDeliveryAttempt struct {
+	ID          uuid.UUID
+	DeliveryID  uuid.UUID
+	StatusCode  sql.NullInt32
+	Error       sql.NullString
+	DurationMs  int32
+	AttemptedAt time.Time
+}
+
+type WebhookEndpoint struct {
+	ID                  uuid.UUID
+	UserID              uuid.NullUUID
+	Url                 string
+	Secret              string
+	Events              []string
+	Enabled             bool
+	ConsecutiveFailures int32
+	DisabledAt          sql.NullTime
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+type WebhookInbox struct {
+	ID            uuid.UUID
+	Provider      string
+	EventID       string
+	Event         string
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int32
+	LastError     sql.NullString
+	NextAttemptAt time.Time
+	ReceivedAt    time.Time
+	ProcessedAt   sql.NullTime
+	UpdatedAt     time.Time
 }
@@ -7,11 +7,55 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const claimClientRefreshToken = `-- name: ClaimClientRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE token = $1 AND client_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+`
+
+type ClaimClientRefreshTokenParams struct {
+	Token    string
+	ClientID uuid.NullUUID
+}
+
+func (q *Queries) ClaimClientRefreshToken(ctx context.Context, arg ClaimClientRefreshTokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimClientRefreshToken, arg.Token, arg.ClientID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createClientRefreshToken = `-- name: CreateClientRefreshToken :exec
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scope)
+VALUES ($1, NOW(), NOW(), $2, $3, null, $4, $5)
+`
+
+type CreateClientRefreshTokenParams struct {
+	Token     string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	ClientID  uuid.NullUUID
+	Scope     sql.NullString
+}
+
+func (q *Queries) CreateClientRefreshToken(ctx context.Context, arg CreateClientRefreshTokenParams) error {
+	_, err := q.db.ExecContext(ctx, createClientRefreshToken,
+		arg.Token,
+		arg.UserID,
+		arg.ExpiresAt,
+		arg.ClientID,
+		arg.Scope,
+	)
+	return err
+}
+
 const createRefreshToken = `-- name: CreateRefreshToken :exec
 INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
 VALUES ($1, NOW(), NOW(), $2, $3, null)
@@ -29,7 +73,7 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 }
 
 const getRefreshTokenByToken = `-- name: GetRefreshTokenByToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scope
 FROM refresh_tokens WHERE token = $1
 `
 
@@ -43,10 +87,50 @@ func (q *Queries) GetRefreshTokenByToken(ctx context.Context, token string) (Ref
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.ClientID,
+		&i.Scope,
 	)
 	return i, err
 }
 
+const getRefreshTokensByUserID = `-- name: GetRefreshTokensByUserID :many
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scope
+FROM refresh_tokens WHERE user_id = $1
+ORDER BY created_at desc
+`
+
+func (q *Queries) GetRefreshTokensByUserID(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, getRefreshTokensByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshToken
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.Token,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.ClientID,
+			&i.Scope,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const revoke = `-- name: Revoke :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
@@ -57,3 +141,33 @@ func (q *Queries) Revoke(ctx context.Context, token string) error {
 	_, err := q.db.ExecContext(ctx, revoke, token)
 	return err
 }
+
+const revokeAllForUser = `-- name: RevokeAllForUser :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeAllForUser, userID)
+	return err
+}
+
+const revokeClientRefreshToken = `-- name: RevokeClientRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE token = $1 AND client_id = $2
+`
+
+type RevokeClientRefreshTokenParams struct {
+	Token    string
+	ClientID uuid.NullUUID
+}
+
+func (q *Queries) RevokeClientRefreshToken(ctx context.Context, arg RevokeClientRefreshTokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeClientRefreshToken, arg.Token, arg.ClientID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -7,16 +7,49 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 
 	"github.com/google/uuid"
 )
 
+const activateRedSubscription = `-- name: ActivateRedSubscription :execrows
+UPDATE users
+SET is_chirpy_red = TRUE, red_status = 'active', red_period_start = $1, red_period_end = $2, red_grace_until = NULL, updated_at = NOW()
+WHERE id = $3
+`
+
+type ActivateRedSubscriptionParams struct {
+	RedPeriodStart sql.NullTime
+	RedPeriodEnd   sql.NullTime
+	ID             uuid.UUID
+}
+
+func (q *Queries) ActivateRedSubscription(ctx context.Context, arg ActivateRedSubscriptionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, activateRedSubscription, arg.RedPeriodStart, arg.RedPeriodEnd, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const cancelUserDeletion = `-- name: CancelUserDeletion :exec
+UPDATE users
+SET delete_after = NULL, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
 gen_random_uuid(), NOW(), NOW(), $1, $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, delete_after, totp_secret, totp_enabled, role, status, status_reason, status_expires_at, is_private, notification_preferences, red_status, red_period_start, red_period_end, red_grace_until, totp_last_step
 `
 
 type CreateUserParams struct {
@@ -34,12 +67,95 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.Role,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.IsPrivate,
+		&i.NotificationPreferences,
+		&i.RedStatus,
+		&i.RedPeriodStart,
+		&i.RedPeriodEnd,
+		&i.RedGraceUntil,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
 
+const deleteUsersPastGracePeriod = `-- name: DeleteUsersPastGracePeriod :execrows
+DELETE FROM users
+WHERE delete_after IS NOT NULL AND delete_after < NOW()
+`
+
+func (q *Queries) DeleteUsersPastGracePeriod(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUsersPastGracePeriod)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const disableUserTotp = `-- name: DisableUserTotp :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled = FALSE, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) DisableUserTotp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, disableUserTotp, id)
+	return err
+}
+
+const enableUserTotp = `-- name: EnableUserTotp :exec
+UPDATE users
+SET totp_enabled = TRUE, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) EnableUserTotp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, enableUserTotp, id)
+	return err
+}
+
+const endRedSubscription = `-- name: EndRedSubscription :execrows
+UPDATE users
+SET is_chirpy_red = FALSE, red_status = $1, red_grace_until = NULL, updated_at = NOW()
+WHERE id = $2
+`
+
+type EndRedSubscriptionParams struct {
+	RedStatus string
+	ID        uuid.UUID
+}
+
+func (q *Queries) EndRedSubscription(ctx context.Context, arg EndRedSubscriptionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, endRedSubscription, arg.RedStatus, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const expireRedSubscriptions = `-- name: ExpireRedSubscriptions :execrows
+UPDATE users
+SET is_chirpy_red = FALSE, red_status = 'expired', red_grace_until = NULL, updated_at = NOW()
+WHERE red_status IN ('active', 'past_due', 'canceled')
+AND COALESCE(red_grace_until, red_period_end) < NOW()
+`
+
+func (q *Queries) ExpireRedSubscriptions(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, expireRedSubscriptions)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red from users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, delete_after, totp_secret, totp_enabled, role, status, status_reason, status_expires_at, is_private, notification_preferences, red_status, red_period_start, red_period_end, red_grace_until, totp_last_step from users
 WHERE email = $1
 `
 
@@ -53,15 +169,357 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.Role,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.IsPrivate,
+		&i.NotificationPreferences,
+		&i.RedStatus,
+		&i.RedPeriodStart,
+		&i.RedPeriodEnd,
+		&i.RedGraceUntil,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, delete_after, totp_secret, totp_enabled, role, status, status_reason, status_expires_at, is_private, notification_preferences, red_status, red_period_start, red_period_end, red_grace_until, totp_last_step from users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.Role,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.IsPrivate,
+		&i.NotificationPreferences,
+		&i.RedStatus,
+		&i.RedPeriodStart,
+		&i.RedPeriodEnd,
+		&i.RedGraceUntil,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
 
+const scheduleUserDeletion = `-- name: ScheduleUserDeletion :exec
+UPDATE users
+SET delete_after = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type ScheduleUserDeletionParams struct {
+	DeleteAfter sql.NullTime
+	ID          uuid.UUID
+}
+
+func (q *Queries) ScheduleUserDeletion(ctx context.Context, arg ScheduleUserDeletionParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleUserDeletion, arg.DeleteAfter, arg.ID)
+	return err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, delete_after, totp_secret, totp_enabled, role, status, status_reason, status_expires_at, is_private, notification_preferences, red_status, red_period_start, red_period_end, red_grace_until, totp_last_step from users
+WHERE $1::text = '' OR email ILIKE '%' || $1::text || '%' ESCAPE '\'
+ORDER BY created_at desc
+LIMIT $2 OFFSET $3
+`
+
+type SearchUsersParams struct {
+	Column1 string
+	Limit   int32
+	Offset  int32
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers, arg.Column1, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.DeleteAfter,
+			&i.TotpSecret,
+			&i.TotpEnabled,
+			&i.Role,
+			&i.Status,
+			&i.StatusReason,
+			&i.StatusExpiresAt,
+			&i.IsPrivate,
+			&i.NotificationPreferences,
+			&i.RedStatus,
+			&i.RedPeriodStart,
+			&i.RedPeriodEnd,
+			&i.RedGraceUntil,
+			&i.TotpLastStep,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setNotificationPreferences = `-- name: SetNotificationPreferences :one
+UPDATE users
+SET notification_preferences = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, delete_after, totp_secret, totp_enabled, role, status, status_reason, status_expires_at, is_private, notification_preferences, red_status, red_period_start, red_period_end, red_grace_until, totp_last_step
+`
+
+type SetNotificationPreferencesParams struct {
+	NotificationPreferences json.RawMessage
+	ID                      uuid.UUID
+}
+
+func (q *Queries) SetNotificationPreferences(ctx context.Context, arg SetNotificationPreferencesParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setNotificationPreferences, arg.NotificationPreferences, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.Role,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.IsPrivate,
+		&i.NotificationPreferences,
+		&i.RedStatus,
+		&i.RedPeriodStart,
+		&i.RedPeriodEnd,
+		&i.RedGraceUntil,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const setRedSubscriptionStatus = `-- name: SetRedSubscriptionStatus :execrows
+UPDATE users
+SET red_status = $1, red_grace_until = $2, updated_at = NOW()
+WHERE id = $3
+`
+
+type SetRedSubscriptionStatusParams struct {
+	RedStatus     string
+	RedGraceUntil sql.NullTime
+	ID            uuid.UUID
+}
+
+func (q *Queries) SetRedSubscriptionStatus(ctx context.Context, arg SetRedSubscriptionStatusParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setRedSubscriptionStatus, arg.RedStatus, arg.RedGraceUntil, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setUserPrivacy = `-- name: SetUserPrivacy :one
+UPDATE users
+SET is_private = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, delete_after, totp_secret, totp_enabled, role, status, status_reason, status_expires_at, is_private, notification_preferences, red_status, red_period_start, red_period_end, red_grace_until, totp_last_step
+`
+
+type SetUserPrivacyParams struct {
+	IsPrivate bool
+	ID        uuid.UUID
+}
+
+func (q *Queries) SetUserPrivacy(ctx context.Context, arg SetUserPrivacyParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserPrivacy, arg.IsPrivate, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.Role,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.IsPrivate,
+		&i.NotificationPreferences,
+		&i.RedStatus,
+		&i.RedPeriodStart,
+		&i.RedPeriodEnd,
+		&i.RedGraceUntil,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, delete_after, totp_secret, totp_enabled, role, status, status_reason, status_expires_at, is_private, notification_preferences, red_status, red_period_start, red_period_end, red_grace_until, totp_last_step
+`
+
+type SetUserRoleParams struct {
+	Role string
+	ID   uuid.UUID
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.Role, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.Role,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.IsPrivate,
+		&i.NotificationPreferences,
+		&i.RedStatus,
+		&i.RedPeriodStart,
+		&i.RedPeriodEnd,
+		&i.RedGraceUntil,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const setUserRoleByEmail = `-- name: SetUserRoleByEmail :execrows
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE email = $2
+`
+
+type SetUserRoleByEmailParams struct {
+	Role  string
+	Email string
+}
+
+func (q *Queries) SetUserRoleByEmail(ctx context.Context, arg SetUserRoleByEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserRoleByEmail, arg.Role, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setUserStatus = `-- name: SetUserStatus :one
+UPDATE users
+SET status = $1, status_reason = $2, status_expires_at = $3, updated_at = NOW()
+WHERE id = $4
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, delete_after, totp_secret, totp_enabled, role, status, status_reason, status_expires_at, is_private, notification_preferences, red_status, red_period_start, red_period_end, red_grace_until, totp_last_step
+`
+
+type SetUserStatusParams struct {
+	Status          string
+	StatusReason    sql.NullString
+	StatusExpiresAt sql.NullTime
+	ID              uuid.UUID
+}
+
+func (q *Queries) SetUserStatus(ctx context.Context, arg SetUserStatusParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserStatus,
+		arg.Status,
+		arg.StatusReason,
+		arg.StatusExpiresAt,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.Role,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.IsPrivate,
+		&i.NotificationPreferences,
+		&i.RedStatus,
+		&i.RedPeriodStart,
+		&i.RedPeriodEnd,
+		&i.RedGraceUntil,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const setUserTotpSecret = `-- name: SetUserTotpSecret :exec
+UPDATE users
+SET totp_secret = $1, totp_enabled = FALSE, updated_at = NOW()
+WHERE id = $2
+`
+
+type SetUserTotpSecretParams struct {
+	TotpSecret sql.NullString
+	ID         uuid.UUID
+}
+
+func (q *Queries) SetUserTotpSecret(ctx context.Context, arg SetUserTotpSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setUserTotpSecret, arg.TotpSecret, arg.ID)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET email = $1, hashed_password = $2, updated_at = NOW()
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, delete_after, totp_secret, totp_enabled, role, status, status_reason, status_expires_at, is_private, notification_preferences, red_status, red_period_start, red_period_end, red_grace_until, totp_last_step
 `
 
 type UpdateUserParams struct {
@@ -80,17 +538,39 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.Role,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.IsPrivate,
+		&i.NotificationPreferences,
+		&i.RedStatus,
+		&i.RedPeriodStart,
+		&i.RedPeriodEnd,
+		&i.RedGraceUntil,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
 
-const upgradeUserToRed = `-- name: UpgradeUserToRed :exec
+const useTotpStep = `-- name: UseTotpStep :execrows
 UPDATE users
-SET is_chirpy_red = TRUE, updated_at = NOW()
-WHERE id = $1
+SET totp_last_step = $1, updated_at = NOW()
+WHERE id = $2 AND totp_last_step < $1
 `
 
-func (q *Queries) UpgradeUserToRed(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeUserToRed, id)
-	return err
+type UseTotpStepParams struct {
+	TotpLastStep int64
+	ID           uuid.UUID
+}
+
+func (q *Queries) UseTotpStep(ctx context.Context, arg UseTotpStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTotpStep, arg.TotpLastStep, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
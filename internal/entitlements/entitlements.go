@@ -0,0 +1,79 @@
+package entitlements
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+type Plan string
+
+const (
+	PlanFree Plan = "free"
+	PlanRed  Plan = "red"
+)
+
+// Capabilities are the limits a plan grants. Every one of them is enforced
+// by the API; add a capability here together with the code that checks it.
+// A RequestsPerMinute of zero leaves requests unlimited.
+type Capabilities struct {
+	Plan              Plan
+	MaxChirpLength    int
+	RequestsPerMinute int
+}
+
+// DefaultPlans is what each plan means today. Changing what Red includes is
+// a change to this table, not to the handlers that enforce it.
+var DefaultPlans = map[Plan]Capabilities{
+	PlanFree: {
+		Plan:              PlanFree,
+		MaxChirpLength:    140,
+		RequestsPerMinute: 60,
+	},
+	PlanRed: {
+		Plan:              PlanRed,
+		MaxChirpLength:    500,
+		RequestsPerMinute: 300,
+	},
+}
+
+// Resolver is how handlers find out what a user may do.
+type Resolver interface {
+	Resolve(ctx context.Context, userID uuid.UUID) (Capabilities, error)
+}
+
+type UserGetter interface {
+	GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error)
+}
+
+// PlanResolver derives capabilities from the user's plan.
+type PlanResolver struct {
+	users UserGetter
+	plans map[Plan]Capabilities
+}
+
+func NewPlanResolver(users UserGetter, plans map[Plan]Capabilities) *PlanResolver {
+	return &PlanResolver{
+		users: users,
+		plans: plans,
+	}
+}
+
+func (p *PlanResolver) Resolve(ctx context.Context, userID uuid.UUID) (Capabilities, error) {
+	user, err := p.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	return p.plans[PlanFor(user)], nil
+}
+
+// PlanFor returns the plan a user is on. Red stays in effect through a
+// cancellation or failed payment until the subscription actually expires.
+func PlanFor(user database.User) Plan {
+	if user.IsChirpyRed {
+		return PlanRed
+	}
+	return PlanFree
+}
@@ -0,0 +1,76 @@
+package entitlements
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/database"
+)
+
+type fakeUsers map[uuid.UUID]database.User
+
+func (f fakeUsers) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, ok := f[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func TestResolve(t *testing.T) {
+	free := database.User{ID: uuid.New()}
+	red := database.User{ID: uuid.New(), IsChirpyRed: true, RedStatus: "active"}
+	canceled := database.User{ID: uuid.New(), IsChirpyRed: true, RedStatus: "canceled"}
+	expired := database.User{ID: uuid.New(), RedStatus: "expired"}
+
+	resolver := NewPlanResolver(fakeUsers{
+		free.ID:     free,
+		red.ID:      red,
+		canceled.ID: canceled,
+		expired.ID:  expired,
+	}, DefaultPlans)
+
+	tests := []struct {
+		name     string
+		userID   uuid.UUID
+		wantPlan Plan
+	}{
+		{name: "Free user", userID: free.ID, wantPlan: PlanFree},
+		{name: "Red user", userID: red.ID, wantPlan: PlanRed},
+		{name: "Canceled keeps Red until expiry", userID: canceled.ID, wantPlan: PlanRed},
+		{name: "Expired user", userID: expired.ID, wantPlan: PlanFree},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capabilities, err := resolver.Resolve(context.Background(), tt.userID)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if capabilities != DefaultPlans[tt.wantPlan] {
+				t.Errorf("Resolve() = %+v, want %+v", capabilities, DefaultPlans[tt.wantPlan])
+			}
+		})
+	}
+}
+
+func TestResolveUnknownUser(t *testing.T) {
+	resolver := NewPlanResolver(fakeUsers{}, DefaultPlans)
+
+	_, err := resolver.Resolve(context.Background(), uuid.New())
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Resolve() error = %v, want %v", err, sql.ErrNoRows)
+	}
+}
+
+func TestRedIsAtLeastFree(t *testing.T) {
+	free, red := DefaultPlans[PlanFree], DefaultPlans[PlanRed]
+
+	if red.MaxChirpLength < free.MaxChirpLength ||
+		red.RequestsPerMinute < free.RequestsPerMinute {
+		t.Errorf("Red grants less than Free: red = %+v, free = %+v", red, free)
+	}
+}
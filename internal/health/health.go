@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Check is one dependency the server needs in order to serve traffic.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type Result struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// Run executes every check concurrently, each bounded by timeout, and returns
+// the results in the order of checks.
+func Run(ctx context.Context, checks []Check, timeout time.Duration) []Result {
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Run(ctx)
+			results[i] = Result{
+				Name:     check.Name,
+				Err:      err,
+				Duration: time.Since(start),
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func Database(db *sql.DB) Check {
+	return Check{
+		Name: "database",
+		Run:  db.PingContext,
+	}
+}
+
+// Migrations checks that goose has applied the schema version this build
+// expects, so that an instance started before its migrations ran does not take
+// traffic. A newer schema passes, since old instances keep serving while a
+// deploy rolls out.
+func Migrations(db *sql.DB, want int64) Check {
+	return Check{
+		Name: "migrations",
+		Run: func(ctx context.Context) error {
+			var version int64
+			err := db.QueryRowContext(ctx,
+				"SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied",
+			).Scan(&version)
+			if err != nil {
+				return fmt.Errorf("cannot read schema version: %w", err)
+			}
+			if version < want {
+				return fmt.Errorf("schema version is %d, want %d", version, want)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,48 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	checks := []Check{
+		{
+			Name: "ok",
+			Run:  func(ctx context.Context) error { return nil },
+		},
+		{
+			Name: "failing",
+			Run:  func(ctx context.Context) error { return errors.New("connection refused") },
+		},
+		{
+			Name: "slow",
+			Run: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		},
+	}
+
+	results := Run(context.Background(), checks, time.Millisecond*10)
+
+	if len(results) != len(checks) {
+		t.Fatalf("got %d results, want %d", len(results), len(checks))
+	}
+	for i, result := range results {
+		if result.Name != checks[i].Name {
+			t.Errorf("result %d is %q, want %q", i, result.Name, checks[i].Name)
+		}
+	}
+	if results[0].Err != nil {
+		t.Errorf("ok check failed: %v", results[0].Err)
+	}
+	if results[1].Err == nil {
+		t.Error("expected the failing check to fail")
+	}
+	if !errors.Is(results[2].Err, context.DeadlineExceeded) {
+		t.Errorf("slow check error = %v, want deadline exceeded", results[2].Err)
+	}
+}
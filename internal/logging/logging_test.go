@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		format  string
+		wantErr bool
+	}{
+		{name: "Defaults", level: "", format: "", wantErr: false},
+		{name: "JSON debug", level: "debug", format: "json", wantErr: false},
+		{name: "Upper case", level: "WARN", format: "TEXT", wantErr: false},
+		{name: "Unknown level", level: "verbose", format: "text", wantErr: true},
+		{name: "Unknown format", level: "info", format: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&bytes.Buffer{}, tt.level, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequestIDIsLogged(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "req-123")
+	logger.With("component", "test").InfoContext(ctx, "handled")
+
+	var record map[string]any
+	err = json.Unmarshal(buf.Bytes(), &record)
+	if err != nil {
+		t.Fatalf("invalid JSON log line %q: %v", buf.String(), err)
+	}
+	if record["request_id"] != "req-123" {
+		t.Errorf("request_id = %v, want req-123", record["request_id"])
+	}
+	if record["component"] != "test" {
+		t.Errorf("component = %v, want test", record["component"])
+	}
+}
+
+func TestTraceIsLogged(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	logger.InfoContext(ctx, "handled")
+
+	var record map[string]any
+	err = json.Unmarshal(buf.Bytes(), &record)
+	if err != nil {
+		t.Fatalf("invalid JSON log line %q: %v", buf.String(), err)
+	}
+	if record["trace_id"] != traceID.String() {
+		t.Errorf("trace_id = %v, want %s", record["trace_id"], traceID)
+	}
+	if record["span_id"] != spanID.String() {
+		t.Errorf("span_id = %v, want %s", record["span_id"], spanID)
+	}
+}
+
+func TestLevelFilters(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", "text")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
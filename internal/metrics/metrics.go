@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "chirpy"
+
+// Metrics holds every collector the server exports. All methods are safe to
+// call on a nil *Metrics, which records nothing, so handlers and tests do not
+// need a registry to run.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	requestsInFlight prometheus.Gauge
+
+	chirpsCreated     prometheus.Counter
+	logins            prometheus.Counter
+	failedLogins      *prometheus.CounterVec
+	webhooksReceived  *prometheus.CounterVec
+	webhooksProcessed *prometheus.CounterVec
+	webhookDeliveries *prometheus.CounterVec
+}
+
+// New registers the HTTP and business collectors together with Go runtime,
+// process and, when db is not nil, connection pool statistics.
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		requestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests currently being served.",
+		}),
+
+		chirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "chirps_created_total",
+			Help:      "Chirps created.",
+		}),
+		logins: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Successful logins, by any method.",
+		}),
+		failedLogins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "failed_logins_total",
+			Help:      "Rejected login attempts by reason.",
+		}, []string{"reason"}),
+		webhooksReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_events_received_total",
+			Help:      "Incoming webhook events stored in the inbox, by provider and event.",
+		}, []string{"provider", "event"}),
+		webhooksProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_events_processed_total",
+			Help:      "Incoming webhook processing attempts by provider and outcome.",
+		}, []string{"provider", "outcome"}),
+		webhookDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_deliveries_total",
+			Help:      "Outgoing webhook delivery attempts by event and outcome.",
+		}, []string{"event", "outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.requestsInFlight,
+		m.chirpsCreated,
+		m.logins,
+		m.failedLogins,
+		m.webhooksReceived,
+		m.webhooksProcessed,
+		m.webhookDeliveries,
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+	}
+
+	return m
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) RequestStarted() {
+	if m == nil {
+		return
+	}
+	m.requestsInFlight.Inc()
+}
+
+// RequestFinished records a served request. route is the ServeMux pattern
+// rather than the path so that IDs in URLs do not explode the label space.
+func (m *Metrics) RequestFinished(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	if route == "" {
+		route = "unmatched"
+	}
+	m.requestsInFlight.Dec()
+	m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+func (m *Metrics) ChirpCreated() {
+	if m == nil {
+		return
+	}
+	m.chirpsCreated.Inc()
+}
+
+func (m *Metrics) LoginSucceeded() {
+	if m == nil {
+		return
+	}
+	m.logins.Inc()
+}
+
+func (m *Metrics) LoginFailed(reason string) {
+	if m == nil {
+		return
+	}
+	m.failedLogins.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) WebhookReceived(provider, event string) {
+	if m == nil {
+		return
+	}
+	m.webhooksReceived.WithLabelValues(provider, event).Inc()
+}
+
+func (m *Metrics) WebhookProcessed(provider, outcome string) {
+	if m == nil {
+		return
+	}
+	m.webhooksProcessed.WithLabelValues(provider, outcome).Inc()
+}
+
+func (m *Metrics) WebhookDelivered(event, outcome string) {
+	if m == nil {
+		return
+	}
+	m.webhookDeliveries.WithLabelValues(event, outcome).Inc()
+}
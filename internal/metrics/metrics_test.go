@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrape status = %d", rec.Code)
+	}
+
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatalf("reading scrape: %v", err)
+	}
+	return string(body)
+}
+
+func TestMetricsExposition(t *testing.T) {
+	m := New(nil)
+
+	m.RequestStarted()
+	m.RequestFinished("GET", "GET /api/chirps/{chirpID}", http.StatusOK, time.Millisecond*20)
+	m.RequestStarted()
+	m.RequestFinished("GET", "", http.StatusNotFound, time.Millisecond)
+	m.ChirpCreated()
+	m.LoginSucceeded()
+	m.LoginFailed("invalid_password")
+	m.WebhookReceived("polka", "user.upgraded")
+	m.WebhookProcessed("polka", "done")
+	m.WebhookDelivered("chirp.created", "delivered")
+
+	body := scrape(t, m)
+
+	for _, want := range []string{
+		`chirpy_http_requests_total{method="GET",route="GET /api/chirps/{chirpID}",status="200"} 1`,
+		`chirpy_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`chirpy_http_request_duration_seconds_count{method="GET",route="GET /api/chirps/{chirpID}"} 1`,
+		`chirpy_http_requests_in_flight 0`,
+		`chirpy_chirps_created_total 1`,
+		`chirpy_logins_total 1`,
+		`chirpy_failed_logins_total{reason="invalid_password"} 1`,
+		`chirpy_webhook_events_received_total{event="user.upgraded",provider="polka"} 1`,
+		`chirpy_webhook_events_processed_total{outcome="done",provider="polka"} 1`,
+		`chirpy_webhook_deliveries_total{event="chirp.created",outcome="delivered"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape is missing %s", want)
+		}
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+
+	m.RequestStarted()
+	m.RequestFinished("GET", "/", http.StatusOK, time.Millisecond)
+	m.ChirpCreated()
+	m.LoginSucceeded()
+	m.LoginFailed("unknown_user")
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
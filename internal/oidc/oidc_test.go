@@ -0,0 +1,192 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockProvider is a minimal OpenID Connect provider that issues a single
+// authorization code and enforces PKCE on the token endpoint.
+type mockProvider struct {
+	server        *httptest.Server
+	key           *rsa.PrivateKey
+	clientID      string
+	code          string
+	codeChallenge string
+	nonce         string
+	emailVerified bool
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	m := &mockProvider{
+		key:           key,
+		clientID:      "chirpy",
+		code:          "auth-code",
+		emailVerified: true,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                m.server.URL,
+			"authorization_endpoint":                m.server.URL + "/authorize",
+			"token_endpoint":                        m.server.URL + "/token",
+			"jwks_uri":                              m.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if r.Form.Get("code") != m.code || base64.RawURLEncoding.EncodeToString(sum[:]) != m.codeChallenge {
+			w.Header().Set("content-type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            m.server.URL,
+			"aud":            m.clientID,
+			"sub":            "external-user",
+			"email":          "user@example.com",
+			"email_verified": m.emailVerified,
+			"nonce":          m.nonce,
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		})
+		token.Header["kid"] = "test"
+		idToken, _ := token.SignedString(m.key)
+
+		w.Header().Set("content-type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// authorize plays the part of the browser visiting the authorization URL.
+func (m *mockProvider) authorize(t *testing.T, authURL string) {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("invalid auth URL: %v", err)
+	}
+
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", query.Get("code_challenge_method"))
+	}
+	m.codeChallenge = query.Get("code_challenge")
+	m.nonce = query.Get("nonce")
+}
+
+func TestProviderExchange(t *testing.T) {
+	tests := []struct {
+		name          string
+		verifier      string
+		nonce         string
+		emailVerified bool
+		wantErr       bool
+	}{
+		{
+			name:          "Valid flow",
+			verifier:      "correct-verifier-correct-verifier-correct",
+			nonce:         "nonce",
+			emailVerified: true,
+			wantErr:       false,
+		},
+		{
+			name:          "Unverified email is reported",
+			verifier:      "correct-verifier-correct-verifier-correct",
+			nonce:         "nonce",
+			emailVerified: false,
+			wantErr:       false,
+		},
+		{
+			name:          "Wrong PKCE verifier",
+			verifier:      "wrong-verifier-wrong-verifier-wrong-verifier",
+			nonce:         "nonce",
+			emailVerified: true,
+			wantErr:       true,
+		},
+		{
+			name:          "Nonce mismatch",
+			verifier:      "correct-verifier-correct-verifier-correct",
+			nonce:         "other-nonce",
+			emailVerified: true,
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockProvider(t)
+			mock.emailVerified = tt.emailVerified
+
+			provider, err := NewProvider(context.Background(), ProviderConfig{
+				Name:        "mock",
+				IssuerURL:   mock.server.URL,
+				ClientID:    mock.clientID,
+				RedirectURL: "http://localhost:8080/api/login/oidc/mock/callback",
+			})
+			if err != nil {
+				t.Fatalf("NewProvider() error = %v", err)
+			}
+
+			mock.authorize(t, provider.AuthCodeURL("state", "nonce", "correct-verifier-correct-verifier-correct"))
+
+			identity, err := provider.Exchange(context.Background(), mock.code, tt.verifier, tt.nonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exchange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if identity.Subject != "external-user" || identity.Email != "user@example.com" {
+				t.Errorf("Exchange() identity = %+v", identity)
+			}
+			if identity.EmailVerified != tt.emailVerified {
+				t.Errorf("Exchange() EmailVerified = %v, want %v", identity.EmailVerified, tt.emailVerified)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnknownEpoch means an event ID was issued by another hub, usually before
+// the process restarted, so it says nothing about what the client missed.
+var ErrUnknownEpoch = errors.New("event ID is from another epoch")
+
+// Event is a single change pushed to subscribers. IDs are assigned by the hub
+// and increase monotonically for its lifetime, so clients can resume from the
+// last one seen. Topic optionally names the resource the event belongs to,
+// such as a conversation.
+type Event struct {
+	ID     uint64
+	Type   string
+	UserID uuid.UUID
+	Topic  uuid.UUID
+	Data   []byte
+}
+
+// Hub fans events out to subscribers. Publishing never blocks: a subscriber
+// whose buffer is full is dropped and has to reconnect, resuming from the
+// hub's history with its last event ID.
+type Hub struct {
+	mu          sync.Mutex
+	epoch       string
+	lastID      uint64
+	history     []Event
+	historySize int
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	Events <-chan Event
+	events chan Event
+	hub    *Hub
+}
+
+func NewHub(historySize, bufferSize int) *Hub {
+	return &Hub{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// EventID formats an event's ID for clients. Event IDs restart with the
+// process, so they carry the hub's epoch to tell them apart across restarts.
+func (h *Hub) EventID(event Event) string {
+	return h.epoch + "-" + strconv.FormatUint(event.ID, 10)
+}
+
+// ParseEventID reads an ID produced by EventID. An ID from another epoch
+// returns ErrUnknownEpoch.
+func (h *Hub) ParseEventID(value string) (uint64, error) {
+	epoch, id, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, fmt.Errorf("malformed event ID %q", value)
+	}
+
+	parsed, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed event ID %q", value)
+	}
+	if epoch != h.epoch {
+		return 0, ErrUnknownEpoch
+	}
+
+	return parsed, nil
+}
+
+func (h *Hub) Publish(event Event) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event.ID = h.lastID
+
+	h.history = append(h.history, event)
+	if len(h.history) > h.historySize {
+		h.history = h.history[len(h.history)-h.historySize:]
+	}
+
+	for sub := range h.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			h.remove(sub)
+		}
+	}
+
+	return event
+}
+
+// Subscribe registers a new subscriber and returns the retained events
+// published after lastEventID, which should be sent before anything read
+// from the subscription. Pass 0 to skip the replay.
+func (h *Hub) Subscribe(lastEventID uint64) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := make(chan Event, h.bufferSize)
+	sub := &Subscription{Events: events, events: events, hub: h}
+	h.subscribers[sub] = struct{}{}
+
+	var missed []Event
+	if lastEventID > 0 {
+		for _, event := range h.history {
+			if event.ID > lastEventID {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	return sub, missed
+}
+
+// Close unregisters the subscription. It is safe to call after the hub has
+// already dropped it.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s)
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subscribers[sub]; !ok {
+		return
+	}
+	delete(h.subscribers, sub)
+	close(sub.events)
+}
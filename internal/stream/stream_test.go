@@ -0,0 +1,111 @@
+package stream
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPublishDelivers(t *testing.T) {
+	hub := NewHub(10, 4)
+	sub, _ := hub.Subscribe(0)
+	defer sub.Close()
+
+	userID := uuid.New()
+	published := hub.Publish(Event{Type: "chirp.created", UserID: userID, Data: []byte(`{}`)})
+
+	event := <-sub.Events
+	if event.ID != published.ID || event.Type != "chirp.created" || event.UserID != userID {
+		t.Errorf("got %+v, want %+v", event, published)
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	hub := NewHub(10, 1)
+	sub, _ := hub.Subscribe(0)
+
+	hub.Publish(Event{Type: "chirp.created", UserID: uuid.New()})
+	hub.Publish(Event{Type: "chirp.created", UserID: uuid.New()})
+
+	if _, ok := <-sub.Events; !ok {
+		t.Fatal("expected the buffered event before the channel closed")
+	}
+	if _, ok := <-sub.Events; ok {
+		t.Fatal("expected the subscription to be closed")
+	}
+
+	sub.Close()
+}
+
+func TestSubscribeReplaysHistory(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastEventID uint64
+		wantIDs     []uint64
+	}{
+		{
+			name:        "No resume",
+			lastEventID: 0,
+			wantIDs:     nil,
+		},
+		{
+			name:        "Resume mid-history",
+			lastEventID: 3,
+			wantIDs:     []uint64{4, 5},
+		},
+		{
+			name:        "Resume before retained history",
+			lastEventID: 1,
+			wantIDs:     []uint64{3, 4, 5},
+		},
+		{
+			name:        "Up to date",
+			lastEventID: 5,
+			wantIDs:     nil,
+		},
+	}
+
+	hub := NewHub(3, 1)
+	for range 5 {
+		hub.Publish(Event{Type: "chirp.created", UserID: uuid.New()})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, missed := hub.Subscribe(tt.lastEventID)
+			defer sub.Close()
+
+			if len(missed) != len(tt.wantIDs) {
+				t.Fatalf("Subscribe() replayed %d events, want %d", len(missed), len(tt.wantIDs))
+			}
+			for i, event := range missed {
+				if event.ID != tt.wantIDs[i] {
+					t.Errorf("Subscribe() event %d has ID %d, want %d", i, event.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseEventID(t *testing.T) {
+	hub := NewHub(3, 1)
+	published := hub.Publish(Event{Type: "chirp.created", UserID: uuid.New()})
+
+	id, err := hub.ParseEventID(hub.EventID(published))
+	if err != nil || id != published.ID {
+		t.Errorf("ParseEventID(EventID()) = %d, %v, want %d", id, err, published.ID)
+	}
+
+	// A hub created later, as after a restart, has a different epoch.
+	restarted := &Hub{epoch: hub.epoch + "x"}
+	if _, err := restarted.ParseEventID(hub.EventID(published)); !errors.Is(err, ErrUnknownEpoch) {
+		t.Errorf("ParseEventID() from another epoch error = %v, want %v", err, ErrUnknownEpoch)
+	}
+
+	for _, value := range []string{"1", "abc", hub.epoch + "-x"} {
+		if _, err := hub.ParseEventID(value); err == nil || errors.Is(err, ErrUnknownEpoch) {
+			t.Errorf("ParseEventID(%q) error = %v, want a malformed ID error", value, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/joaogiacometti/goserver/internal/database"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/joaogiacometti/goserver/internal/tracing"
+
+// DB wraps the connection handed to database.New so that every generated
+// query runs in a client span named after the query.
+type DB struct {
+	db     database.DBTX
+	tracer trace.Tracer
+}
+
+func WrapDB(db database.DBTX) *DB {
+	return &DB{
+		db:     db,
+		tracer: otel.Tracer(instrumentationName),
+	}
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := d.start(ctx, query)
+	defer span.End()
+
+	result, err := d.db.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return result, err
+}
+
+func (d *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := d.start(ctx, query)
+	defer span.End()
+
+	stmt, err := d.db.PrepareContext(ctx, query)
+	recordError(span, err)
+	return stmt, err
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := d.start(ctx, query)
+	defer span.End()
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+// QueryRowContext ends its span before the row is scanned, so the span covers
+// running the query but not reading its result.
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := d.start(ctx, query)
+	defer span.End()
+
+	row := d.db.QueryRowContext(ctx, query, args...)
+	if err := row.Err(); err != sql.ErrNoRows {
+		recordError(span, err)
+	}
+	return row
+}
+
+func (d *DB) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := queryName(query)
+	return d.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(name),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+// queryName reads the name sqlc writes at the top of every query, such as
+// "-- name: GetUserByID :one".
+func queryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "db.query"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
+
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return recorder
+}
+
+func TestMiddlewareContinuesTrace(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := Middleware(RouteSpans(mux))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/api/chirps/123", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "GET /api/chirps/{chirpID}" {
+		t.Errorf("span name = %q", span.Name())
+	}
+	if span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("span kind = %v, want server", span.SpanKind())
+	}
+	if got := span.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("trace ID = %s, want %s", got, traceID)
+	}
+	if !span.Parent().IsRemote() {
+		t.Error("expected the span to have the remote parent from traceparent")
+	}
+}
+
+type fakeDB struct {
+	err error
+}
+
+func (f fakeDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, f.err
+}
+
+func (f fakeDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, f.err
+}
+
+func (f fakeDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, f.err
+}
+
+func (f fakeDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestWrapDB(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+
+	db := WrapDB(fakeDB{})
+	db.ExecContext(ctx, "-- name: DeleteChirpByID :exec\nDELETE FROM chirps WHERE id = $1", 1)
+
+	failing := WrapDB(fakeDB{err: errors.New("connection refused")})
+	failing.QueryContext(ctx, "SELECT 1")
+
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+
+	tests := []struct {
+		name       string
+		wantStatus codes.Code
+	}{
+		{name: "DeleteChirpByID", wantStatus: codes.Unset},
+		{name: "db.query", wantStatus: codes.Error},
+	}
+	for i, tt := range tests {
+		span := spans[i]
+		if span.Name() != tt.name {
+			t.Errorf("span %d name = %q, want %q", i, span.Name(), tt.name)
+		}
+		if span.Status().Code != tt.wantStatus {
+			t.Errorf("span %q status = %v, want %v", tt.name, span.Status().Code, tt.wantStatus)
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %q is not a child of the request span", tt.name)
+		}
+	}
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		wantErr  bool
+	}{
+		{name: "Disabled", exporter: "", wantErr: false},
+		{name: "Stdout", exporter: ExporterStdout, wantErr: false},
+		{name: "Unknown", exporter: "zipkin", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), tt.exporter, "chirpy-test", &discard{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if err := shutdown(context.Background()); err != nil {
+					t.Errorf("shutdown() error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) {
+	return len(p), nil
+}
@@ -0,0 +1,195 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+)
+
+const (
+	SignatureHeader = "X-Chirpy-Signature"
+	TimestampHeader = "X-Chirpy-Timestamp"
+	EventHeader     = "X-Chirpy-Event"
+	DeliveryHeader  = "X-Chirpy-Delivery"
+)
+
+// NewClient returns a client that bounds every attempt and does not follow
+// redirects, so an endpoint cannot bounce a signed payload somewhere it was
+// not registered. Unless allowPrivateNetworks is set it also refuses to
+// connect to any address in nonPublicPrefixes. That check runs on the address being dialled, after DNS resolution, so a host
+// that resolves to an internal address, or is rebound to one after it was
+// registered, is refused as well.
+func NewClient(allowPrivateNetworks bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   time.Second * 5,
+		KeepAlive: time.Second * 30,
+	}
+	if !allowPrivateNetworks {
+		dialer.Control = refusePrivateAddress
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be the address dialled, hiding the real destination.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   time.Second * 10,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// DefaultClient only reaches public addresses.
+var DefaultClient = NewClient(false)
+
+// nonPublicPrefixes are the IANA special-purpose ranges a webhook must not
+// reach: anything that is not a globally routed unicast address, plus the
+// IPv6 ranges that embed or translate to an IPv4 address.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("10.0.0.0/8"),      // private
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("127.0.0.0/8"),     // loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // link-local, including cloud metadata
+	netip.MustParsePrefix("172.16.0.0/12"),   // private
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("192.88.99.0/24"),  // 6to4 relay anycast
+	netip.MustParsePrefix("192.168.0.0/16"),  // private
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("224.0.0.0/4"),     // multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+	netip.MustParsePrefix("::/96"),           // unspecified, loopback and IPv4-compatible
+	netip.MustParsePrefix("::ffff:0:0/96"),   // IPv4-mapped
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("100::/64"),        // discard-only
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments, including Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4
+	netip.MustParsePrefix("3fff::/20"),       // documentation
+	netip.MustParsePrefix("fc00::/7"),        // unique local
+	netip.MustParsePrefix("fe80::/10"),       // link-local
+	netip.MustParsePrefix("ff00::/8"),        // multicast
+}
+
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+
+	// Prefix.Contains never matches an address with a zone, and an
+	// IPv4-mapped address only matches IPv4 prefixes once unmapped.
+	ip = ip.WithZone("").Unmap()
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+		}
+	}
+	return nil
+}
+
+// maxResponseSize caps how much of a receiver's response body is read before
+// the connection is released.
+const maxResponseSize = 64 << 10
+
+var (
+	ErrInvalidURL       = errors.New("invalid webhook URL")
+	ErrForbiddenAddress = errors.New("webhook endpoint is not a public address")
+	ErrUnexpectedStatus = errors.New("unexpected webhook response status")
+)
+
+// Delivery is one event bound for one endpoint. Its ID stays the same across
+// retries so receivers can deduplicate.
+type Delivery struct {
+	ID      uuid.UUID
+	Event   string
+	Payload []byte
+}
+
+type Result struct {
+	StatusCode int
+	Duration   time.Duration
+}
+
+// ValidateURL accepts absolute http and https URLs with a host name. Plain
+// http and IP address hosts are only allowed when allowInsecure is set.
+// Where a host name resolves to is checked when connecting; see NewClient.
+func ValidateURL(rawURL string, allowInsecure bool) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ErrInvalidURL
+	}
+
+	if _, err := netip.ParseAddr(u.Hostname()); err == nil && !allowInsecure {
+		return ErrInvalidURL
+	}
+
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if allowInsecure {
+			return nil
+		}
+	}
+	return ErrInvalidURL
+}
+
+// Send posts a delivery to endpointURL, signed with the same "sha256=<hex>"
+// HMAC of "<timestamp>.<body>" that incoming webhooks are verified with. Any
+// 2xx response is a success; StatusCode is zero when no response arrived.
+func Send(ctx context.Context, client *http.Client, endpointURL, secret string, delivery Delivery, now time.Time) (Result, error) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %s", ErrInvalidURL, err)
+	}
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("user-agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(SignatureHeader, "sha256="+auth.SignWebhook(secret, timestamp, delivery.Payload))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	result := Result{Duration: time.Since(start)}
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,233 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaogiacometti/goserver/internal/auth"
+)
+
+func TestSendSignsDelivery(t *testing.T) {
+	delivery := Delivery{
+		ID:      uuid.New(),
+		Event:   "chirp.created",
+		Payload: []byte(`{"event":"chirp.created","data":{}}`),
+	}
+
+	var received *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	now := time.Now()
+	result, err := Send(context.Background(), receiver.Client(), receiver.URL, "secret", delivery, now)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if result.StatusCode != http.StatusNoContent {
+		t.Errorf("StatusCode = %d, want %d", result.StatusCode, http.StatusNoContent)
+	}
+
+	if received.Method != http.MethodPost {
+		t.Errorf("Method = %s, want POST", received.Method)
+	}
+	if got := received.Header.Get(EventHeader); got != delivery.Event {
+		t.Errorf("%s = %q, want %q", EventHeader, got, delivery.Event)
+	}
+	if got := received.Header.Get(DeliveryHeader); got != delivery.ID.String() {
+		t.Errorf("%s = %q, want %q", DeliveryHeader, got, delivery.ID)
+	}
+	if string(body) != string(delivery.Payload) {
+		t.Errorf("body = %s, want %s", body, delivery.Payload)
+	}
+
+	err = auth.VerifyWebhook(
+		"secret",
+		received.Header.Get(SignatureHeader),
+		received.Header.Get(TimestampHeader),
+		body,
+		now,
+	)
+	if err != nil {
+		t.Errorf("VerifyWebhook() error = %v", err)
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+	delivery := Delivery{ID: uuid.New(), Event: "chirp.deleted", Payload: []byte(`{}`)}
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+	}{
+		{
+			name: "Server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "Redirect is not followed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/elsewhere", http.StatusFound)
+			},
+			wantStatus: http.StatusFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := httptest.NewServer(tt.handler)
+			defer receiver.Close()
+
+			client := *DefaultClient
+			client.Transport = receiver.Client().Transport
+
+			result, err := Send(context.Background(), &client, receiver.URL, "secret", delivery, time.Now())
+			if !errors.Is(err, ErrUnexpectedStatus) {
+				t.Errorf("Send() error = %v, want %v", err, ErrUnexpectedStatus)
+			}
+			if result.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", result.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSendUnreachable(t *testing.T) {
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	url := receiver.URL
+	receiver.Close()
+
+	delivery := Delivery{ID: uuid.New(), Event: "chirp.created", Payload: []byte(`{}`)}
+
+	result, err := Send(context.Background(), NewClient(true), url, "secret", delivery, time.Now())
+	if err == nil {
+		t.Fatal("expected an error for an unreachable endpoint")
+	}
+	if result.StatusCode != 0 {
+		t.Errorf("StatusCode = %d, want 0", result.StatusCode)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		name          string
+		url           string
+		allowInsecure bool
+		wantErr       bool
+	}{
+		{name: "HTTPS", url: "https://example.com/hooks", wantErr: false},
+		{name: "HTTP not allowed", url: "http://example.com/hooks", wantErr: true},
+		{name: "HTTP allowed", url: "http://example.com/hooks", allowInsecure: true, wantErr: false},
+		{name: "Relative", url: "/hooks", wantErr: true},
+		{name: "Other scheme", url: "ftp://example.com/hooks", allowInsecure: true, wantErr: true},
+		{name: "IPv4 host", url: "https://169.254.169.254/latest", wantErr: true},
+		{name: "IPv6 host", url: "https://[::1]:8080/hooks", wantErr: true},
+		{name: "IP host allowed", url: "http://127.0.0.1:8080/hooks", allowInsecure: true, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateURL(tt.url, tt.allowInsecure)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRefusePrivateAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		wantErr bool
+	}{
+		{name: "Public IPv4", address: "93.184.215.14:443"},
+		{name: "Public IPv6", address: "[2606:4700::6810:84e5]:443"},
+		{name: "Public IPv4, mapped", address: "[::ffff:93.184.215.14]:443"},
+		{name: "Loopback", address: "127.0.0.1:443", wantErr: true},
+		{name: "Private", address: "10.1.2.3:443", wantErr: true},
+		{name: "Carrier-grade NAT", address: "100.64.0.1:443", wantErr: true},
+		{name: "This network", address: "0.1.2.3:443", wantErr: true},
+		{name: "Benchmarking", address: "198.18.0.1:443", wantErr: true},
+		{name: "Cloud metadata", address: "169.254.169.254:80", wantErr: true},
+		{name: "Broadcast", address: "255.255.255.255:443", wantErr: true},
+		{name: "Multicast", address: "224.0.0.1:443", wantErr: true},
+		{name: "IPv6 loopback", address: "[::1]:443", wantErr: true},
+		{name: "IPv4-mapped loopback", address: "[::ffff:127.0.0.1]:443", wantErr: true},
+		{name: "IPv4-compatible private", address: "[::10.0.0.1]:443", wantErr: true},
+		{name: "NAT64", address: "[64:ff9b::a00:1]:443", wantErr: true},
+		{name: "6to4", address: "[2002:a00:1::1]:443", wantErr: true},
+		{name: "Teredo", address: "[2001:0:4136:e378::1]:443", wantErr: true},
+		{name: "Unique local", address: "[fd00::1]:443", wantErr: true},
+		{name: "Link-local with zone", address: "[fe80::1%eth0]:443", wantErr: true},
+		{name: "Not an IP", address: "example.com:443", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := refusePrivateAddress("tcp", tt.address, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("refusePrivateAddress(%q) error = %v, wantErr %v", tt.address, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrForbiddenAddress) {
+				t.Errorf("refusePrivateAddress(%q) error = %v, want %v", tt.address, err, ErrForbiddenAddress)
+			}
+		})
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	var reached bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	_, port, err := net.SplitHostPort(receiver.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delivery := Delivery{ID: uuid.New(), Event: "chirp.created", Payload: []byte(`{}`)}
+
+	tests := []struct {
+		name    string
+		client  *http.Client
+		url     string
+		wantErr error
+	}{
+		{name: "Loopback address", client: DefaultClient, url: receiver.URL, wantErr: ErrForbiddenAddress},
+		{name: "Name resolving to loopback", client: DefaultClient, url: "http://localhost:" + port, wantErr: ErrForbiddenAddress},
+		{name: "Private networks allowed", client: NewClient(true), url: receiver.URL, wantErr: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached = false
+
+			_, err := Send(context.Background(), tt.client, tt.url, "secret", delivery, time.Now())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Send() error = %v, want %v", err, tt.wantErr)
+			}
+			if reached != (tt.wantErr == nil) {
+				t.Errorf("receiver reached = %v", reached)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+-- name: CreateChirpReport :one
+INSERT INTO chirp_reports (id, chirp_id, chirp_author_id, chirp_body, reporter_id, reason, status, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, 'open', NOW())
+RETURNING *;
+
+-- name: GetChirpReports :many
+SELECT * FROM chirp_reports
+WHERE $1::text = '' OR status = $1
+ORDER BY created_at asc
+LIMIT $2 OFFSET $3;
+
+-- name: GetChirpReportByID :one
+SELECT * FROM chirp_reports
+WHERE id = $1;
+
+-- name: ResolveChirpReport :one
+UPDATE chirp_reports
+SET status = $1, resolution = $2, resolution_note = $3, resolved_by = $4, resolved_at = NOW()
+WHERE id = $5 AND status = 'open'
+RETURNING *;
+
+-- name: ResolveOpenReportsForChirp :exec
+UPDATE chirp_reports
+SET status = $1, resolution = $2, resolution_note = $3, resolved_by = $4, resolved_at = NOW()
+WHERE chirp_id = $5 AND status = 'open';
\ No newline at end of file
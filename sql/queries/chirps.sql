@@ -4,22 +4,85 @@ VALUES (gen_random_uuid(), $1, NOW(), NOW(), $2)
 RETURNING *;
 
 -- name: GetAllAsc :many
-SELECT id, body, created_at, updated_at, user_id
+SELECT id, body, created_at, updated_at, user_id, hidden_at
 FROM chirps
-WHERE $1::uuid = '00000000-0000-0000-0000-000000000000' OR user_id = $1
+WHERE ($1::uuid = '00000000-0000-0000-0000-000000000000' OR user_id = $1)
+AND hidden_at IS NULL
+AND EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.status != 'banned'
+    AND users.delete_after IS NULL
+    AND (
+        NOT users.is_private
+        OR users.id = $2::uuid
+        OR EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $2::uuid
+            AND follows.followee_id = users.id
+            AND follows.status = 'accepted'
+        )
+    )
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = chirps.user_id AND blocked_id = $2::uuid)
+    OR (blocker_id = $2::uuid AND blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE muter_id = $2::uuid AND muted_id = chirps.user_id
+)
 ORDER BY created_at asc;
 
 -- name: GetAllDesc :many
-SELECT id, body, created_at, updated_at, user_id
+SELECT id, body, created_at, updated_at, user_id, hidden_at
 FROM chirps
-WHERE $1::uuid = '00000000-0000-0000-0000-000000000000' OR user_id = $1
+WHERE ($1::uuid = '00000000-0000-0000-0000-000000000000' OR user_id = $1)
+AND hidden_at IS NULL
+AND EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.status != 'banned'
+    AND users.delete_after IS NULL
+    AND (
+        NOT users.is_private
+        OR users.id = $2::uuid
+        OR EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $2::uuid
+            AND follows.followee_id = users.id
+            AND follows.status = 'accepted'
+        )
+    )
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = chirps.user_id AND blocked_id = $2::uuid)
+    OR (blocker_id = $2::uuid AND blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE muter_id = $2::uuid AND muted_id = chirps.user_id
+)
 ORDER BY created_at desc;
 
 -- name: GetChirpByID :one
-SELECT id, body, created_at, updated_at, user_id
+SELECT id, body, created_at, updated_at, user_id, hidden_at
 FROM chirps
 WHERE id = $1;
 
 -- name: DeleteChirpByID :exec
 DELETE FROM chirps
+WHERE id = $1;
+
+-- name: GetChirpsByUserID :many
+SELECT id, body, created_at, updated_at, user_id, hidden_at
+FROM chirps
+WHERE user_id = $1
+ORDER BY created_at asc;
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW(), updated_at = NOW()
 WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,63 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at)
+VALUES (gen_random_uuid(), NOW(), NOW())
+RETURNING *;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, last_read_at, joined_at)
+VALUES ($1, $2, NULL, NOW());
+
+-- name: GetConversationMembers :many
+SELECT * FROM conversation_members
+WHERE conversation_id = $1
+ORDER BY joined_at asc;
+
+-- name: IsConversationMember :one
+SELECT EXISTS (
+    SELECT 1 FROM conversation_members
+    WHERE conversation_id = $1 AND user_id = $2
+);
+
+-- name: CreateDirectConversation :one
+INSERT INTO conversations (id, created_at, updated_at, direct_key)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1)
+ON CONFLICT (direct_key) DO UPDATE SET direct_key = EXCLUDED.direct_key
+RETURNING id, created_at, updated_at, direct_key, (xmax = 0)::boolean AS inserted;
+
+-- name: GetMembersOfConversations :many
+SELECT * FROM conversation_members
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY conversation_id, joined_at asc;
+
+-- name: GetConversationsForUser :many
+SELECT conversations.id, conversations.created_at, conversations.updated_at, conversation_members.last_read_at,
+    (
+        SELECT COUNT(*) FROM messages
+        WHERE messages.conversation_id = conversations.id
+        AND messages.sender_id != conversation_members.user_id
+        AND (conversation_members.last_read_at IS NULL OR messages.created_at > conversation_members.last_read_at)
+    ) AS unread_count
+FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = $1
+ORDER BY conversations.updated_at desc;
+
+-- name: HasBlockInConversation :one
+SELECT EXISTS (
+    SELECT 1 FROM conversation_members
+    JOIN user_blocks ON (
+        (user_blocks.blocker_id = conversation_members.user_id AND user_blocks.blocked_id = sqlc.arg(user_id))
+        OR (user_blocks.blocker_id = sqlc.arg(user_id) AND user_blocks.blocked_id = conversation_members.user_id)
+    )
+    WHERE conversation_members.conversation_id = sqlc.arg(conversation_id)
+);
+
+-- name: MarkConversationRead :execrows
+UPDATE conversation_members
+SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
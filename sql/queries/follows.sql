@@ -0,0 +1,56 @@
+-- name: CreateFollow :one
+INSERT INTO follows (follower_id, followee_id, status, created_at, updated_at)
+VALUES ($1, $2, $3, NOW(), NOW())
+ON CONFLICT (follower_id, followee_id) DO NOTHING
+RETURNING *;
+
+-- name: GetFollow :one
+SELECT * FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: DeleteFollow :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = $1 AND followee_id = $2)
+OR (follower_id = $2 AND followee_id = $1);
+
+-- name: AcceptFollowRequest :execrows
+UPDATE follows
+SET status = 'accepted', updated_at = NOW()
+WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending';
+
+-- name: AcceptPendingFollows :exec
+UPDATE follows
+SET status = 'accepted', updated_at = NOW()
+WHERE followee_id = $1 AND status = 'pending';
+
+-- name: GetFollowers :many
+SELECT * FROM follows
+WHERE followee_id = $1 AND status = $2
+ORDER BY created_at desc;
+
+-- name: GetFollowing :many
+SELECT * FROM follows
+WHERE follower_id = $1 AND status = $2
+ORDER BY created_at desc;
+
+-- name: CanViewUserChirps :one
+SELECT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = sqlc.arg(author_id)
+    AND users.status != 'banned'
+    AND users.delete_after IS NULL
+    AND (
+        NOT users.is_private
+        OR users.id = sqlc.arg(viewer_id)
+        OR EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = sqlc.arg(viewer_id)
+            AND follows.followee_id = users.id
+            AND follows.status = 'accepted'
+        )
+    )
+);
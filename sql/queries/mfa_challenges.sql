@@ -0,0 +1,26 @@
+-- name: CreateMFAChallenge :one
+INSERT INTO mfa_challenges (id, user_id, failed_attempts, created_at, expires_at, used_at)
+VALUES (gen_random_uuid(), $1, 0, NOW(), $2, NULL)
+RETURNING *;
+
+-- name: GetMFAChallengeByID :one
+SELECT * FROM mfa_challenges
+WHERE id = $1;
+
+-- name: RecordMFAChallengeFailure :exec
+UPDATE mfa_challenges
+SET failed_attempts = failed_attempts + 1
+WHERE id = $1;
+
+-- name: UseMFAChallenge :execrows
+UPDATE mfa_challenges
+SET used_at = NOW()
+WHERE id = $1 AND used_at IS NULL;
+
+-- name: CreateMFAFailure :exec
+INSERT INTO mfa_failures (id, user_id, source, created_at)
+VALUES (gen_random_uuid(), $1, $2, NOW());
+
+-- name: CountRecentMFAFailures :one
+SELECT COUNT(*)::INTEGER AS failures FROM mfa_failures
+WHERE user_id = $1 AND created_at > $2;
@@ -0,0 +1,25 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, user_id, actor_id, type, subject_id, read_at, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NULL, NOW())
+RETURNING *;
+
+-- name: GetNotifications :many
+SELECT * FROM notifications
+WHERE user_id = $1
+AND ($2::boolean = false OR read_at IS NULL)
+ORDER BY created_at desc
+LIMIT $3 OFFSET $4;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND user_id = $2;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
@@ -0,0 +1,26 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, owner_id, name, secret_hash, redirect_uris, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW())
+RETURNING *;
+
+-- name: GetOAuthClientByID :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+
+-- name: GetOAuthClientsByOwner :many
+SELECT * FROM oauth_clients
+WHERE owner_id = $1
+ORDER BY created_at asc;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = $1 AND owner_id = $2;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scope, code_challenge, created_at, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, NOW(), $7);
+
+-- name: TakeOAuthAuthorizationCode :one
+DELETE FROM oauth_authorization_codes
+WHERE code_hash = $1 AND expires_at > NOW()
+RETURNING *;
\ No newline at end of file
@@ -0,0 +1,20 @@
+-- name: CreateOidcLoginState :exec
+INSERT INTO oidc_login_states (state, provider, nonce, code_verifier, created_at, expires_at)
+VALUES ($1, $2, $3, $4, NOW(), $5);
+
+-- name: TakeOidcLoginState :one
+DELETE FROM oidc_login_states
+WHERE state = $1 AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeleteExpiredOidcLoginStates :exec
+DELETE FROM oidc_login_states
+WHERE expires_at <= NOW();
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE provider = $1 AND subject = $2;
+
+-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (provider, subject, user_id, email, created_at, updated_at)
+VALUES ($1, $2, $3, $4, NOW(), NOW());
\ No newline at end of file
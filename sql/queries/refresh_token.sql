@@ -2,11 +2,35 @@
 INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
 VALUES ($1, NOW(), NOW(), $2, $3, null);
 
+-- name: CreateClientRefreshToken :exec
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scope)
+VALUES ($1, NOW(), NOW(), $2, $3, null, $4, $5);
+
 -- name: GetRefreshTokenByToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scope
 FROM refresh_tokens WHERE token = $1;
 
 -- name: Revoke :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1;
\ No newline at end of file
+WHERE token = $1;
+
+-- name: GetRefreshTokensByUserID :many
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scope
+FROM refresh_tokens WHERE user_id = $1
+ORDER BY created_at desc;
+
+-- name: RevokeAllForUser :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeClientRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE token = $1 AND client_id = $2;
+
+-- name: ClaimClientRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE token = $1 AND client_id = $2 AND revoked_at IS NULL AND expires_at > NOW();
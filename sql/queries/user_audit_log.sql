@@ -0,0 +1,8 @@
+-- name: CreateUserAuditLog :exec
+INSERT INTO user_audit_log (id, user_id, actor_id, action, reason, expires_at, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, NOW());
+
+-- name: GetUserAuditLog :many
+SELECT * FROM user_audit_log
+WHERE user_id = $1
+ORDER BY created_at desc;
\ No newline at end of file
@@ -0,0 +1,34 @@
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM user_blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: GetBlockedUsers :many
+SELECT * FROM user_blocks
+WHERE blocker_id = $1
+ORDER BY created_at desc;
+
+-- name: IsBlockedBetween :one
+SELECT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = $1 AND blocked_id = $2)
+    OR (blocker_id = $2 AND blocked_id = $1)
+);
+
+-- name: MuteUser :exec
+INSERT INTO user_mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnmuteUser :execrows
+DELETE FROM user_mutes
+WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: GetMutedUsers :many
+SELECT * FROM user_mutes
+WHERE muter_id = $1
+ORDER BY created_at desc;
@@ -15,7 +15,95 @@ SET email = $1, hashed_password = $2, updated_at = NOW()
 WHERE id = $3
 RETURNING *;
 
--- name: UpgradeUserToRed :exec
+-- name: ActivateRedSubscription :execrows
 UPDATE users
-SET is_chirpy_red = TRUE, updated_at = NOW()
-WHERE id = $1;
\ No newline at end of file
+SET is_chirpy_red = TRUE, red_status = 'active', red_period_start = $1, red_period_end = $2, red_grace_until = NULL, updated_at = NOW()
+WHERE id = $3;
+
+-- name: SetRedSubscriptionStatus :execrows
+UPDATE users
+SET red_status = $1, red_grace_until = $2, updated_at = NOW()
+WHERE id = $3;
+
+-- name: EndRedSubscription :execrows
+UPDATE users
+SET is_chirpy_red = FALSE, red_status = $1, red_grace_until = NULL, updated_at = NOW()
+WHERE id = $2;
+
+-- name: ExpireRedSubscriptions :execrows
+UPDATE users
+SET is_chirpy_red = FALSE, red_status = 'expired', red_grace_until = NULL, updated_at = NOW()
+WHERE red_status IN ('active', 'past_due', 'canceled')
+AND COALESCE(red_grace_until, red_period_end) < NOW();
+
+-- name: GetUserByID :one
+SELECT * from users
+WHERE id = $1;
+
+-- name: ScheduleUserDeletion :exec
+UPDATE users
+SET delete_after = $1, updated_at = NOW()
+WHERE id = $2;
+
+-- name: CancelUserDeletion :exec
+UPDATE users
+SET delete_after = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteUsersPastGracePeriod :execrows
+DELETE FROM users
+WHERE delete_after IS NOT NULL AND delete_after < NOW();
+
+-- name: SetUserTotpSecret :exec
+UPDATE users
+SET totp_secret = $1, totp_enabled = FALSE, updated_at = NOW()
+WHERE id = $2;
+
+-- name: EnableUserTotp :exec
+UPDATE users
+SET totp_enabled = TRUE, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DisableUserTotp :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled = FALSE, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SearchUsers :many
+SELECT * from users
+WHERE $1::text = '' OR email ILIKE '%' || $1::text || '%' ESCAPE '\'
+ORDER BY created_at desc
+LIMIT $2 OFFSET $3;
+
+-- name: SetNotificationPreferences :one
+UPDATE users
+SET notification_preferences = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: SetUserPrivacy :one
+UPDATE users
+SET is_private = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: SetUserRoleByEmail :execrows
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE email = $2;
+
+-- name: SetUserStatus :one
+UPDATE users
+SET status = $1, status_reason = $2, status_expires_at = $3, updated_at = NOW()
+WHERE id = $4
+RETURNING *;
+-- name: UseTotpStep :execrows
+UPDATE users
+SET totp_last_step = $1, updated_at = NOW()
+WHERE id = $2 AND totp_last_step < $1;
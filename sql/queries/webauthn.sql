@@ -0,0 +1,31 @@
+-- name: CreateWebauthnCredential :exec
+INSERT INTO webauthn_credentials (id, user_id, credential, created_at, updated_at, last_used_at)
+VALUES ($1, $2, $3, NOW(), NOW(), null);
+
+-- name: GetWebauthnCredentialsByUserID :many
+SELECT * FROM webauthn_credentials
+WHERE user_id = $1
+ORDER BY created_at asc;
+
+-- name: UpdateWebauthnCredential :exec
+UPDATE webauthn_credentials
+SET credential = $1, last_used_at = NOW(), updated_at = NOW()
+WHERE id = $2;
+
+-- name: DeleteWebauthnCredential :execrows
+DELETE FROM webauthn_credentials
+WHERE id = $1 AND user_id = $2;
+
+-- name: CreateWebauthnSession :one
+INSERT INTO webauthn_sessions (id, data, created_at, expires_at)
+VALUES (gen_random_uuid(), $1, NOW(), $2)
+RETURNING id;
+
+-- name: TakeWebauthnSession :one
+DELETE FROM webauthn_sessions
+WHERE id = $1 AND expires_at > NOW()
+RETURNING data;
+
+-- name: DeleteExpiredWebauthnSessions :exec
+DELETE FROM webauthn_sessions
+WHERE expires_at <= NOW();
\ No newline at end of file
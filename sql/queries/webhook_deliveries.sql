@@ -0,0 +1,55 @@
+-- name: CreateWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, endpoint_id, event, payload, status, attempts, next_attempt_at, created_at, updated_at)
+SELECT gen_random_uuid(), id, sqlc.arg(event)::text, sqlc.arg(payload)::jsonb, 'pending', 0, NOW(), NOW(), NOW()
+FROM webhook_endpoints
+WHERE enabled
+AND sqlc.arg(event)::text = ANY(events)
+AND (user_id IS NULL OR user_id = sqlc.arg(user_id)::uuid);
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET status = 'processing', attempts = attempts + 1, next_attempt_at = $1, updated_at = NOW()
+WHERE id IN (
+    SELECT webhook_deliveries.id FROM webhook_deliveries
+    JOIN webhook_endpoints ON webhook_endpoints.id = webhook_deliveries.endpoint_id
+    WHERE webhook_deliveries.status IN ('pending', 'processing')
+    AND webhook_deliveries.next_attempt_at <= NOW()
+    AND webhook_endpoints.enabled
+    ORDER BY webhook_deliveries.created_at asc
+    LIMIT $2
+    FOR UPDATE OF webhook_deliveries SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'delivered', last_status_code = $1, last_error = NULL, delivered_at = NOW(), updated_at = NOW()
+WHERE id = $2;
+
+-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'pending', last_status_code = $1, last_error = $2, next_attempt_at = $3, updated_at = NOW()
+WHERE id = $4;
+
+-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'failed', last_status_code = $1, last_error = $2, updated_at = NOW()
+WHERE id = $3;
+
+-- name: GetWebhookDeliveryByID :one
+SELECT * FROM webhook_deliveries WHERE id = $1;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at desc
+LIMIT $2 OFFSET $3;
+
+-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, status_code, error, duration_ms, attempted_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW());
+
+-- name: GetWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempted_at asc;
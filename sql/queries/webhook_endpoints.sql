@@ -0,0 +1,38 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, user_id, url, secret, events, enabled, consecutive_failures, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, TRUE, 0, NOW(), NOW())
+RETURNING *;
+
+-- name: GetWebhookEndpointByID :one
+SELECT * FROM webhook_endpoints WHERE id = $1;
+
+-- name: GetWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = $1 OR ($2::bool AND user_id IS NULL)
+ORDER BY created_at asc;
+
+-- name: DeleteWebhookEndpoint :exec
+DELETE FROM webhook_endpoints WHERE id = $1;
+
+-- name: EnableWebhookEndpoint :one
+UPDATE webhook_endpoints
+SET enabled = TRUE, consecutive_failures = 0, disabled_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    enabled = enabled AND consecutive_failures + 1 < sqlc.arg(max_failures)::integer,
+    disabled_at = CASE
+        WHEN enabled AND consecutive_failures + 1 >= sqlc.arg(max_failures)::integer THEN NOW()
+        ELSE disabled_at
+    END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ResetWebhookEndpointFailures :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0, updated_at = NOW()
+WHERE id = $1 AND consecutive_failures > 0;
@@ -0,0 +1,50 @@
+-- name: EnqueueWebhookEvent :execrows
+INSERT INTO webhook_inbox (id, provider, event_id, event, payload, status, attempts, next_attempt_at, received_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, 'pending', 0, NOW(), NOW(), NOW())
+ON CONFLICT (provider, event_id) DO NOTHING;
+
+-- name: ClaimWebhookEvents :many
+UPDATE webhook_inbox
+SET status = 'processing', attempts = attempts + 1, next_attempt_at = $1, updated_at = NOW()
+WHERE id IN (
+    SELECT inbox.id FROM webhook_inbox inbox
+    WHERE inbox.status IN ('pending', 'processing') AND inbox.next_attempt_at <= NOW()
+    AND NOT EXISTS (
+        SELECT 1 FROM webhook_inbox earlier
+        WHERE earlier.provider = inbox.provider
+        AND earlier.payload->'data'->>'user_id' = inbox.payload->'data'->>'user_id'
+        AND earlier.status IN ('pending', 'processing')
+        AND earlier.received_at < inbox.received_at
+    )
+    ORDER BY inbox.received_at asc
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteWebhookEvent :exec
+UPDATE webhook_inbox
+SET status = 'done', last_error = NULL, processed_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: RetryWebhookEvent :exec
+UPDATE webhook_inbox
+SET status = 'pending', last_error = $1, next_attempt_at = $2, updated_at = NOW()
+WHERE id = $3;
+
+-- name: FailWebhookEvent :exec
+UPDATE webhook_inbox
+SET status = 'failed', last_error = $1, updated_at = NOW()
+WHERE id = $2;
+
+-- name: GetWebhookEvents :many
+SELECT * FROM webhook_inbox
+WHERE $1::text = '' OR status = $1::text
+ORDER BY received_at desc
+LIMIT $2 OFFSET $3;
+
+-- name: ReplayWebhookEvent :one
+UPDATE webhook_inbox
+SET status = 'pending', attempts = 0, last_error = NULL, next_attempt_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status = 'failed'
+RETURNING *;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS delete_after TIMESTAMP NULL;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN IF EXISTS delete_after;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS totp_secret TEXT NULL,
+ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE recovery_codes(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL
+);
+
+-- +goose Down
+DROP TABLE recovery_codes;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS totp_enabled,
+DROP COLUMN IF EXISTS totp_secret;
\ No newline at end of file
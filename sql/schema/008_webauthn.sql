@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE webauthn_credentials(
+    id BYTEA PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NULL
+);
+
+CREATE TABLE webauthn_sessions(
+    id UUID PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE webauthn_sessions;
+DROP TABLE webauthn_credentials;
\ No newline at end of file
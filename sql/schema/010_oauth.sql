@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE oauth_clients(
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    secret_hash TEXT NULL,
+    redirect_uris TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE oauth_authorization_codes(
+    code_hash TEXT PRIMARY KEY,
+    client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    code_challenge TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+ALTER TABLE refresh_tokens
+ADD COLUMN IF NOT EXISTS client_id UUID NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+ADD COLUMN IF NOT EXISTS scope TEXT NULL;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP COLUMN IF EXISTS scope,
+DROP COLUMN IF EXISTS client_id;
+
+DROP TABLE oauth_authorization_codes;
+DROP TABLE oauth_clients;
\ No newline at end of file
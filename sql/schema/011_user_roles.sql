@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user',
+ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP NULL;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN IF EXISTS suspended_at,
+DROP COLUMN IF EXISTS role;
\ No newline at end of file
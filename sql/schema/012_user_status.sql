@@ -0,0 +1,33 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active',
+ADD COLUMN IF NOT EXISTS status_reason TEXT NULL,
+ADD COLUMN IF NOT EXISTS status_expires_at TIMESTAMP NULL;
+
+UPDATE users SET status = 'suspended' WHERE suspended_at IS NOT NULL;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS suspended_at;
+
+CREATE TABLE user_audit_log(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    reason TEXT NULL,
+    expires_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE user_audit_log;
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP NULL;
+
+UPDATE users SET suspended_at = NOW() WHERE status <> 'active';
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS status_expires_at,
+DROP COLUMN IF EXISTS status_reason,
+DROP COLUMN IF EXISTS status;
\ No newline at end of file
@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP NULL;
+
+CREATE TABLE chirp_reports(
+    id UUID PRIMARY KEY,
+    chirp_id UUID NULL REFERENCES chirps(id) ON DELETE SET NULL,
+    chirp_author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_body TEXT NOT NULL,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open',
+    resolution TEXT NULL,
+    resolution_note TEXT NULL,
+    resolved_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (chirp_id, reporter_id)
+);
+
+-- +goose Down
+DROP TABLE chirp_reports;
+
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS hidden_at;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE follows(
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (follower_id, followee_id)
+);
+
+-- +goose Down
+DROP TABLE follows;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS is_private;
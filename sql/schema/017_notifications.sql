@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS notification_preferences JSONB NOT NULL DEFAULT '{}';
+
+CREATE TABLE notifications(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    type TEXT NOT NULL,
+    subject_id UUID NULL,
+    read_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX notifications_user_id_created_at_idx ON notifications (user_id, created_at);
+
+-- +goose Down
+DROP TABLE notifications;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS notification_preferences;
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS red_status TEXT NOT NULL DEFAULT 'none',
+ADD COLUMN IF NOT EXISTS red_period_start TIMESTAMP NULL,
+ADD COLUMN IF NOT EXISTS red_period_end TIMESTAMP NULL,
+ADD COLUMN IF NOT EXISTS red_grace_until TIMESTAMP NULL;
+
+UPDATE users
+SET red_status = 'active'
+WHERE is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN IF EXISTS red_grace_until,
+DROP COLUMN IF EXISTS red_period_end,
+DROP COLUMN IF EXISTS red_period_start,
+DROP COLUMN IF EXISTS red_status;
@@ -0,0 +1,38 @@
+-- +goose Up
+CREATE TABLE webhook_inbox(
+    id UUID PRIMARY KEY,
+    provider TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    received_at TIMESTAMP NOT NULL,
+    processed_at TIMESTAMP NULL,
+    updated_at TIMESTAMP NOT NULL,
+    UNIQUE (provider, event_id)
+);
+
+CREATE INDEX webhook_inbox_status_next_attempt_at_idx ON webhook_inbox (status, next_attempt_at);
+
+INSERT INTO webhook_inbox (id, provider, event_id, event, payload, status, next_attempt_at, received_at, processed_at, updated_at)
+SELECT gen_random_uuid(), 'polka', id, event, '{}', 'done', received_at, received_at, received_at, received_at
+FROM polka_events;
+
+DROP TABLE polka_events;
+
+-- +goose Down
+CREATE TABLE polka_events(
+    id TEXT PRIMARY KEY,
+    event TEXT NOT NULL,
+    received_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO polka_events (id, event, received_at)
+SELECT event_id, event, received_at
+FROM webhook_inbox
+WHERE provider = 'polka';
+
+DROP TABLE webhook_inbox;
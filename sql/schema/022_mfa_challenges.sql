@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE mfa_challenges(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL
+);
+
+CREATE INDEX mfa_challenges_user_id_created_at_idx ON mfa_challenges (user_id, created_at);
+
+-- +goose Down
+DROP TABLE mfa_challenges;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS totp_last_step;
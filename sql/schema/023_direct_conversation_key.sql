@@ -0,0 +1,33 @@
+-- +goose Up
+ALTER TABLE conversations
+ADD COLUMN direct_key TEXT NULL;
+
+-- Older direct conversations get a key too. Where a race already produced
+-- duplicates, only the oldest one per pair keeps it.
+WITH pairs AS (
+    SELECT conversation_id, MIN(user_id::text) || ':' || MAX(user_id::text) AS direct_key
+    FROM conversation_members
+    GROUP BY conversation_id
+    HAVING COUNT(*) = 2
+), oldest AS (
+    SELECT DISTINCT ON (pairs.direct_key) pairs.conversation_id, pairs.direct_key
+    FROM pairs
+    JOIN conversations ON conversations.id = pairs.conversation_id
+    ORDER BY pairs.direct_key, conversations.created_at
+)
+UPDATE conversations
+SET direct_key = oldest.direct_key
+FROM oldest
+WHERE conversations.id = oldest.conversation_id;
+
+CREATE UNIQUE INDEX conversations_direct_key_idx ON conversations (direct_key);
+
+CREATE INDEX conversation_members_user_id_idx ON conversation_members (user_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS conversation_members_user_id_idx;
+
+DROP INDEX IF EXISTS conversations_direct_key_idx;
+
+ALTER TABLE conversations
+DROP COLUMN IF EXISTS direct_key;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE mfa_failures(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX mfa_failures_user_id_created_at_idx ON mfa_failures (user_id, created_at);
+
+INSERT INTO mfa_failures (id, user_id, source, created_at)
+SELECT gen_random_uuid(), mfa_challenges.user_id, 'login', mfa_challenges.created_at
+FROM mfa_challenges, generate_series(1, mfa_challenges.failed_attempts);
+
+-- +goose Down
+DROP TABLE mfa_failures;